@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/plan"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan>",
+	Short: "apply runs the operations in a plan written by 'tag --plan-out'",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		p, err := plan.Load(args[0])
+		if err != nil {
+			log.Fatalf("Failed to load plan: %s", err)
+		}
+
+		exec, err := newExecutor(cmd)
+		if err != nil {
+			log.Fatalf("Failed to configure execution: %s", err)
+		}
+		defer exec.close(os.Stdout)
+
+		// images which drifted or failed are reported and the rest of the plan is
+		// still applied, so the journal covers every image that was updated
+		drifted, failed := false, false
+
+		for _, image := range p.Images {
+			err := image.Check()
+			if errors.Is(err, plan.ErrDrift) {
+				fmt.Println(image.Path, "skipped:", err)
+				drifted = true
+				continue
+			}
+			if err != nil {
+				fmt.Println(image.Path, "failed:", err)
+				failed = true
+				continue
+			}
+
+			ops := image.Planned()
+			if len(ops) == 0 {
+				continue
+			}
+
+			fmt.Println("Updates to", image.Path)
+			for _, op := range ops {
+				fmt.Printf("  %s\n", op.Reason)
+			}
+
+			err = exec.execute(os.Stdout, image.Path, ops)
+			if err != nil {
+				fmt.Println(image.Path, "failed:", err)
+				failed = true
+			}
+		}
+
+		if drifted || failed {
+			exec.close(os.Stdout)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	addExecutorFlags(applyCmd)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/config"
+	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
+)
+
+// datasetOptions control how the GPX data is read and how images are matched to its points
+type datasetOptions struct {
+	read             gpx.ReadOptions
+	interpolation    gpx.Interpolation
+	maxGap           time.Duration
+	stationaryRadius float64
+}
+
+// addDatasetFlags adds the flags used by getDatasetOptions to cmd
+func addDatasetFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"interpolation",
+		string(gpx.InterpolationNearest),
+		"Method used to find positions between GPX points: nearest, linear or great-circle",
+	)
+	cmd.Flags().Duration(
+		"max-gap",
+		gpx.DefaultMaxGap,
+		"Maximum time between an image and a GPX point for the point to be used, images beyond it are not tagged",
+	)
+	cmd.Flags().Float64(
+		"stationary-radius",
+		0,
+		"Distance in meters within which images taken in a pause between GPX track segments use the last point before the pause, by default such images are not tagged",
+	)
+	cmd.Flags().Float64(
+		"max-accuracy",
+		0,
+		"Largest accuracy radius in meters of points to load from files which record it, such as Google Takeout location history, by default all points are loaded",
+	)
+	cmd.Flags().String(
+		"csv-lat",
+		"",
+		"Name of the latitude column in CSV files, defaults to lat or latitude",
+	)
+	cmd.Flags().String(
+		"csv-lon",
+		"",
+		"Name of the longitude column in CSV files, defaults to lon, lng, long or longitude",
+	)
+	cmd.Flags().String(
+		"csv-ele",
+		"",
+		"Name of the elevation column in CSV files, defaults to ele, elevation, alt or altitude when present",
+	)
+	cmd.Flags().String(
+		"csv-time",
+		"",
+		"Name of the time column in CSV files, defaults to time, timestamp or datetime",
+	)
+	cmd.Flags().String(
+		"csv-time-format",
+		"",
+		"Go time layout of the times in CSV files, e.g. '02/01/2006 15:04:05', by default RFC 3339 times and Unix timestamps are read",
+	)
+}
+
+func getDatasetOptions(cmd *cobra.Command) (datasetOptions, error) {
+	var opts datasetOptions
+
+	interpolationName, err := cmd.Flags().GetString("interpolation")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get interpolation flag: %w", err)
+	}
+	opts.interpolation, err = gpx.ParseInterpolation(interpolationName)
+	if err != nil {
+		return opts, fmt.Errorf("invalid interpolation flag: %w", err)
+	}
+
+	opts.maxGap, err = cmd.Flags().GetDuration("max-gap")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get max-gap flag: %w", err)
+	}
+
+	opts.stationaryRadius, err = cmd.Flags().GetFloat64("stationary-radius")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get stationary-radius flag: %w", err)
+	}
+
+	opts.read.MaxAccuracy, err = cmd.Flags().GetFloat64("max-accuracy")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get max-accuracy flag: %w", err)
+	}
+
+	opts.read.CSV, err = getCSVOptions(cmd)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// apply sets the options on the dataset
+func (o datasetOptions) apply(g *gpx.GPXDataset) {
+	g.Interpolation = o.interpolation
+	g.MaxGap = o.maxGap
+	g.StationaryRadius = o.stationaryRadius
+}
+
+// getCSVOptions returns the CSV columns from the csv section of the config file, when it
+// exists, with any csv flags which are set taking precedence
+func getCSVOptions(cmd *cobra.Command) (gpx.CSVOptions, error) {
+	var opts gpx.CSVOptions
+
+	path, err := homedir.Expand(configPath)
+	if err != nil {
+		return opts, fmt.Errorf("error expanding homedir: %w", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return opts, fmt.Errorf("failed to load config: %w", err)
+	}
+	opts = gpx.CSVOptions{
+		Lat:        cfg.CSV.Lat,
+		Lon:        cfg.CSV.Lon,
+		Ele:        cfg.CSV.Ele,
+		Time:       cfg.CSV.Time,
+		TimeFormat: cfg.CSV.TimeFormat,
+	}
+
+	for flag, value := range map[string]*string{
+		"csv-lat":         &opts.Lat,
+		"csv-lon":         &opts.Lon,
+		"csv-ele":         &opts.Ele,
+		"csv-time":        &opts.Time,
+		"csv-time-format": &opts.TimeFormat,
+	} {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+		*value, err = cmd.Flags().GetString(flag)
+		if err != nil {
+			return opts, fmt.Errorf("failed to get %s flag: %w", flag, err)
+		}
+	}
+
+	return opts, nil
+}
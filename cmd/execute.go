@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/journal"
+	"github.com/charlieegan3/gpxif/internal/pkg/operations"
+	"github.com/charlieegan3/gpxif/internal/pkg/utils"
+)
+
+// defaultJournalDir is where journals are written when no path is given
+const defaultJournalDir = "~/.gpxif-journals"
+
+// executor runs operations on images, keeping backups if requested and recording
+// the changes made in a journal
+type executor struct {
+	backup      bool
+	backupDir   string
+	journalPath string
+
+	// journal is only created when the first image is about to be updated
+	journal   *journal.Writer
+	journalMu sync.Mutex
+}
+
+// addExecutorFlags adds the flags used by newExecutor to cmd
+func addExecutorFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(
+		"backup",
+		false,
+		"Keep a copy of each image as <name>.orig before it's updated",
+	)
+	cmd.Flags().String(
+		"backup-dir",
+		"",
+		"Keep a copy of each image in this directory, at its path relative to the working directory, before it's updated, implies --backup",
+	)
+	cmd.Flags().String(
+		"journal",
+		"",
+		"File to record changes in so that they can be undone, defaults to a new file in "+defaultJournalDir,
+	)
+}
+
+func newExecutor(cmd *cobra.Command) (*executor, error) {
+	backup, err := cmd.Flags().GetBool("backup")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup flag: %w", err)
+	}
+
+	backupDir, err := cmd.Flags().GetString("backup-dir")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup-dir flag: %w", err)
+	}
+
+	journalPath, err := cmd.Flags().GetString("journal")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get journal flag: %w", err)
+	}
+	if journalPath == "" {
+		journalDir, err := homedir.Expand(defaultJournalDir)
+		if err != nil {
+			return nil, fmt.Errorf("error expanding homedir: %w", err)
+		}
+		journalPath = journal.DefaultPath(journalDir, time.Now())
+	}
+
+	return &executor{
+		backup:      backup || backupDir != "",
+		backupDir:   backupDir,
+		journalPath: journalPath,
+	}, nil
+}
+
+// execute runs the operations on image and records the changes in the journal, progress
+// is written to out. It's safe to call concurrently for different images.
+//
+// The operations are run on a copy of the image and the journal entry is written before
+// the copy replaces the image, so an image is never changed without a journal entry.
+func (e *executor) execute(out io.Writer, image string, ops []operations.Operation) (err error) {
+	entry, err := journal.NewEntry(image, operations.Changes(ops))
+	if err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	w, err := e.journalWriter()
+	if err != nil {
+		return err
+	}
+
+	if e.backup {
+		backupPath, err := utils.Backup(image, e.backupDir)
+		if err != nil {
+			return fmt.Errorf("failed to backup image: %w", err)
+		}
+		fmt.Fprintln(out, "  Backed up to", backupPath)
+	}
+
+	staged, err := utils.StageCopy(image)
+	if err != nil {
+		return fmt.Errorf("failed to copy image: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(staged)
+		}
+	}()
+
+	err = operations.ExecuteAll(staged, ops)
+	if err != nil {
+		return fmt.Errorf("failed operations: %w", err)
+	}
+
+	err = entry.Complete(staged)
+	if err != nil {
+		return fmt.Errorf("failed to complete journal entry: %w", err)
+	}
+
+	err = w.Write(entry)
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	// if this fails the entry is for an unchanged image, which undo refuses to touch
+	err = utils.ReplaceFile(image, staged)
+	if err != nil {
+		return fmt.Errorf("failed to update image: %w", err)
+	}
+
+	return nil
+}
+
+// journalWriter returns the journal, creating it if it's not yet been used
+func (e *executor) journalWriter() (*journal.Writer, error) {
+	e.journalMu.Lock()
+	defer e.journalMu.Unlock()
+
+	if e.journal == nil {
+		w, err := journal.Create(e.journalPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create journal: %w", err)
+		}
+		e.journal = w
+	}
+
+	return e.journal, nil
+}
+
+// close closes the journal, if one was written, and explains how to undo the changes
+func (e *executor) close(out io.Writer) {
+	if e.journal == nil {
+		return
+	}
+
+	e.journal.Close()
+	fmt.Fprintln(out, "---")
+	fmt.Fprintln(out, "Journal written to", e.journal.Path())
+	fmt.Fprintln(out, "Run 'gpxif undo", e.journal.Path()+"' to revert these changes")
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/discover"
+)
+
+// addDiscoveryFlags adds the flags used by discoveryOptions to cmd
+func addDiscoveryFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP(
+		"recursive",
+		"r",
+		false,
+		"Include images in subdirectories",
+	)
+	cmd.Flags().StringSlice(
+		"include",
+		nil,
+		"Only include files matching these glob patterns, patterns without a / match file names, others match paths where ** matches any number of directories",
+	)
+	cmd.Flags().StringSlice(
+		"exclude",
+		nil,
+		"Skip files and directories matching these glob patterns",
+	)
+	cmd.Flags().String(
+		"files-from",
+		"",
+		"Read a list of files and directories from this file, or stdin when -, one per line or NUL separated as from find -print0",
+	)
+	cmd.Flags().Bool(
+		"follow-symlinks",
+		false,
+		"Search symlinked directories, by default they're skipped. Symlinked files are always included",
+	)
+}
+
+// discoverFiles returns the files for paths, along with any listed in the --files-from file,
+// using the discovery flags
+func discoverFiles(cmd *cobra.Command, paths []string) ([]string, error) {
+	opts, err := discoveryOptions(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	filesFrom, err := cmd.Flags().GetString("files-from")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files-from flag: %w", err)
+	}
+	if filesFrom != "" {
+		var r io.Reader = os.Stdin
+		if filesFrom != "-" {
+			f, err := os.Open(filesFrom)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open files-from list: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		listed, err := discover.ReadList(r)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, listed...)
+	}
+
+	if len(paths) == 0 {
+		// only tag has a flag for a directory of images
+		if cmd.Flags().Lookup("images") != nil {
+			return nil, fmt.Errorf("no images given, use -i, file arguments or --files-from")
+		}
+		return nil, fmt.Errorf("no images given, use file arguments or --files-from")
+	}
+
+	return discover.Paths(paths, opts)
+}
+
+func discoveryOptions(cmd *cobra.Command) (discover.Options, error) {
+	var opts discover.Options
+	var err error
+
+	opts.Recursive, err = cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get recursive flag: %w", err)
+	}
+
+	opts.Include, err = cmd.Flags().GetStringSlice("include")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get include flag: %w", err)
+	}
+
+	opts.Exclude, err = cmd.Flags().GetStringSlice("exclude")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get exclude flag: %w", err)
+	}
+
+	opts.FollowSymlinks, err = cmd.Flags().GetBool("follow-symlinks")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get follow-symlinks flag: %w", err)
+	}
+
+	return opts, opts.Validate()
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/operations"
+)
+
+// addGPSFlags adds the flags used by getGPSOptions to cmd
+func addGPSFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"gps-policy",
+		string(operations.GPSPolicyKeep),
+		"What to do with images which already have GPS data: keep, overwrite, or if-far to replace it when it's further than --gps-threshold from the GPX position",
+	)
+	cmd.Flags().Float64(
+		"gps-threshold",
+		operations.DefaultGPSThreshold,
+		"Distance in meters beyond which --gps-policy=if-far replaces existing GPS data",
+	)
+	cmd.Flags().Int(
+		"altitude-precision",
+		operations.DefaultAltitudePrecision,
+		fmt.Sprintf("Number of decimal places altitudes are written with, up to %d", operations.MaxAltitudePrecision),
+	)
+	cmd.Flags().Bool(
+		"motion",
+		false,
+		"Also write the course and speed of travel from the GPX track along with positions",
+	)
+	cmd.Flags().Float64(
+		"min-speed",
+		operations.DefaultMinSpeed,
+		"Speed in km/h below which --motion writes no course or speed, as the device is treated as standing still",
+	)
+	cmd.Flags().Bool(
+		"img-direction",
+		false,
+		"With --motion, also write the course as GPSImgDirection, assuming the camera faced the direction of travel",
+	)
+}
+
+func getGPSOptions(cmd *cobra.Command) (operations.GPSOptions, error) {
+	var opts operations.GPSOptions
+
+	policyName, err := cmd.Flags().GetString("gps-policy")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get gps-policy flag: %w", err)
+	}
+	opts.Policy, err = operations.ParseGPSPolicy(policyName)
+	if err != nil {
+		return opts, fmt.Errorf("invalid gps-policy flag: %w", err)
+	}
+
+	opts.Threshold, err = cmd.Flags().GetFloat64("gps-threshold")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get gps-threshold flag: %w", err)
+	}
+	if opts.Threshold < 0 {
+		return opts, fmt.Errorf("invalid gps-threshold flag: must not be negative")
+	}
+
+	opts.AltitudePrecision, err = cmd.Flags().GetInt("altitude-precision")
+	if err != nil {
+		return opts, fmt.Errorf("failed to get altitude-precision flag: %w", err)
+	}
+	if opts.AltitudePrecision < 0 || opts.AltitudePrecision > operations.MaxAltitudePrecision {
+		return opts, fmt.Errorf("invalid altitude-precision flag: must be between 0 and %d", operations.MaxAltitudePrecision)
+	}
+
+	return opts, nil
+}
+
+// getMotionOptions returns the options for writing motion, or nil when it's disabled
+func getMotionOptions(cmd *cobra.Command) (*operations.MotionOptions, error) {
+	enabled, err := cmd.Flags().GetBool("motion")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get motion flag: %w", err)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	var opts operations.MotionOptions
+
+	opts.MinSpeed, err = cmd.Flags().GetFloat64("min-speed")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get min-speed flag: %w", err)
+	}
+	if opts.MinSpeed < 0 {
+		return nil, fmt.Errorf("invalid min-speed flag: must not be negative")
+	}
+
+	opts.ImgDirection, err = cmd.Flags().GetBool("img-direction")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get img-direction flag: %w", err)
+	}
+
+	return &opts, nil
+}
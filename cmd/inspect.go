@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/djherbis/times"
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
+	"github.com/charlieegan3/gpxif/internal/pkg/operations"
+	"github.com/charlieegan3/gpxif/internal/pkg/utils"
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [files or directories...]",
+	Short: "inspect shows the times and locations gpxif reads and derives for images",
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := discoverFiles(cmd, args)
+		if err != nil {
+			log.Fatalf("Failed to find images: %s", err)
+		}
+
+		gpxSource, err := cmd.Flags().GetString("gpx")
+		if err != nil {
+			log.Fatalf("Failed to get gpx flag: %s", err)
+		}
+
+		datasetOpts, err := getDatasetOptions(cmd)
+		if err != nil {
+			log.Fatalf("Failed to configure GPX matching: %s", err)
+		}
+
+		var g *gpx.GPXDataset
+		if gpxSource != "" {
+			ds, err := gpx.NewGPXDatasetFromDiskWithOptions(datasetOpts.read, gpxSource)
+			if err != nil {
+				log.Fatalf("Failed to create GPX dataset: %s", err)
+			}
+			datasetOpts.apply(&ds)
+			g = &ds
+		}
+
+		for i, f := range files {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			err := inspect(f, g)
+			if err != nil {
+				log.Fatalf("failed to write output: %s", err)
+			}
+		}
+	},
+}
+
+// inspect prints the values read and derived for an image, g is optional
+func inspect(image string, g *gpx.GPXDataset) error {
+	fmt.Println(image)
+
+	if !utils.IsImageFile(image) {
+		fmt.Println("  not a supported image")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := func(name string, value string) {
+		fmt.Fprintf(w, "  %s\t%s\n", name, value)
+	}
+
+	for _, key := range []string{"DateTimeOriginal", "SubSecTimeOriginal", "OffsetTimeOriginal"} {
+		value, err := exif.GetKey(image, "IFD/Exif", key)
+		row(key, describeValue(value, err))
+	}
+
+	utcTime, utcErr := exif.GetUTC(image)
+	if utcErr != nil {
+		row("UTC", describeValue(nil, utcErr))
+	} else {
+		row("UTC", utcTime.Format(time.RFC3339Nano))
+	}
+
+	current, err := exif.GetGPS(image)
+	switch {
+	case err != nil:
+		row("GPS", describeValue(nil, err))
+	case current == nil:
+		row("GPS", "not set")
+	default:
+		inspectGPS(row, current)
+	}
+
+	t, err := times.Stat(image)
+	if err != nil {
+		row("mtime", describeValue(nil, err))
+	} else {
+		row("mtime", t.ModTime().Format(time.RFC3339Nano))
+	}
+
+	if g != nil && utcErr == nil {
+		inspectMatch(row, utcTime, g)
+	}
+
+	return w.Flush()
+}
+
+// inspectGPS adds rows for the image's existing GPS data
+func inspectGPS(row func(name, value string), g *exif.GPS) {
+	position := fmt.Sprintf("%.6f, %.6f", g.Latitude, g.Longitude)
+	if err := g.Validate(); err != nil {
+		position += fmt.Sprintf(" (invalid: %s)", err)
+	}
+	row("GPS", position)
+
+	if g.Altitude != nil {
+		row("GPS altitude", fmt.Sprintf("%.1fm", *g.Altitude))
+	} else {
+		row("GPS altitude", "not set")
+	}
+
+	if g.Timestamp != nil {
+		row("GPS time", g.Timestamp.Format(time.RFC3339Nano))
+	} else {
+		row("GPS time", "not set")
+	}
+
+	if g.Datum != "" {
+		row("GPS datum", g.Datum)
+	} else {
+		row("GPS datum", "not set")
+	}
+}
+
+// inspectMatch adds rows for the GPX point used for the time, and its timezone
+func inspectMatch(row func(name, value string), utcTime time.Time, g *gpx.GPXDataset) {
+	match, err := g.Match(utcTime)
+	if err != nil {
+		row("GPX point", describeValue(nil, err))
+		return
+	}
+
+	p := match.Point
+	point := fmt.Sprintf("%.6f, %.6f", p.Latitude, p.Longitude)
+	if p.Elevation.NotNull() {
+		point += fmt.Sprintf(", %.1fm", p.Elevation.Value())
+	}
+	row("GPX point", point)
+	row("GPX method", string(match.Method))
+	gap := match.Nearest.Timestamp.Sub(utcTime)
+	relative := "after"
+	if gap < 0 {
+		gap, relative = -gap, "before"
+	}
+	row("GPX nearest", fmt.Sprintf("%s, %s %s the image", match.Nearest.Timestamp.Format(time.RFC3339), gap, relative))
+
+	// CheckLocalTime uses the point from AtTime, which is the same as the match
+	location, err := operations.TimezoneAt(p.Latitude, p.Longitude)
+	if err != nil {
+		row("Timezone", describeValue(nil, err))
+		return
+	}
+	local := utcTime.In(location)
+	row("Timezone", fmt.Sprintf("%s (%s)", location, local.Format("-07:00")))
+	row("Local time", local.Format("2006:01:02 15:04:05"))
+}
+
+// describeValue formats an EXIF value, or the reason it couldn't be read
+func describeValue(value interface{}, err error) string {
+	switch {
+	case errors.Is(err, exif.ErrTagNotFound), err == nil && value == nil:
+		return "not set"
+	case errors.Is(err, exif.ErrNoExif):
+		return "no EXIF data"
+	case err != nil:
+		return "error: " + err.Error()
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.Flags().StringP(
+		"gpx",
+		"g",
+		"",
+		"GPX or other supported location history file, or a directory of them, to show the matched point and timezone from",
+	)
+	addDatasetFlags(inspectCmd)
+	addDiscoveryFlags(inspectCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import "sync/atomic"
+
+// forEachOrdered calls work for each index from 0 to n-1 using up to jobs goroutines, and
+// calls done for each index in order once its work has completed. When done returns false
+// no further work is started, done is still called for the indexes already in progress.
+func forEachOrdered(n, jobs int, work func(i int), done func(i int) bool) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// finished receives whether the work for each index was run
+	finished := make([]chan bool, n)
+	for i := range finished {
+		finished[i] = make(chan bool, 1)
+	}
+
+	// tokens limits the indexes in flight, including those waiting to be passed to done,
+	// so that work never runs more than jobs indexes ahead of done
+	tokens := make(chan struct{}, jobs)
+	var stopped int32
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := 0; i < n; i++ {
+			tokens <- struct{}{}
+			if atomic.LoadInt32(&stopped) == 1 {
+				finished[i] <- false
+				continue
+			}
+			indexes <- i
+		}
+	}()
+
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range indexes {
+				work(i)
+				finished[i] <- true
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		if <-finished[i] && !done(i) {
+			atomic.StoreInt32(&stopped, 1)
+		}
+		<-tokens
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEachOrdered(t *testing.T) {
+	testCases := map[string]struct {
+		N            int
+		Jobs         int
+		StopAt       int
+		ExpectedDone []int
+	}{
+		"serial": {
+			N:            5,
+			Jobs:         1,
+			StopAt:       -1,
+			ExpectedDone: []int{0, 1, 2, 3, 4},
+		},
+		"concurrent": {
+			N:            20,
+			Jobs:         4,
+			StopAt:       -1,
+			ExpectedDone: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19},
+		},
+		"serial stops immediately": {
+			N:            5,
+			Jobs:         1,
+			StopAt:       1,
+			ExpectedDone: []int{0, 1},
+		},
+		"no work": {
+			N:            0,
+			Jobs:         4,
+			StopAt:       -1,
+			ExpectedDone: nil,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var running, maxRunning int32
+
+			var done []int
+			forEachOrdered(
+				testCase.N,
+				testCase.Jobs,
+				func(i int) {
+					r := atomic.AddInt32(&running, 1)
+					for {
+						m := atomic.LoadInt32(&maxRunning)
+						if r <= m || atomic.CompareAndSwapInt32(&maxRunning, m, r) {
+							break
+						}
+					}
+					// later indexes finish first to check that done is still called in order
+					time.Sleep(time.Duration(testCase.N-i) * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+				},
+				func(i int) bool {
+					done = append(done, i)
+					return i != testCase.StopAt
+				},
+			)
+
+			assert.Equal(t, testCase.ExpectedDone, done)
+			assert.LessOrEqual(t, int(maxRunning), testCase.Jobs)
+		})
+	}
+}
+
+func TestForEachOrderedStop(t *testing.T) {
+	var started int32
+
+	var done []int
+	forEachOrdered(
+		100,
+		4,
+		func(i int) {
+			atomic.AddInt32(&started, 1)
+		},
+		func(i int) bool {
+			done = append(done, i)
+			return i != 10
+		},
+	)
+
+	// work already in flight when done stops is still reported, but no more than jobs
+	assert.GreaterOrEqual(t, len(done), 11)
+	assert.LessOrEqual(t, len(done), 11+4)
+	assert.Equal(t, int32(len(done)), atomic.LoadInt32(&started))
+	for i, d := range done {
+		assert.Equal(t, i, d)
+	}
+}
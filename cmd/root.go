@@ -6,6 +6,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// configPath is the config file read by commands
+const configPath = "~/.gpxif"
+
 var rootCmd = &cobra.Command{
 	Use:   "gpxif",
 	Short: "CLI to update image EXIF locations and times using a GPX track",
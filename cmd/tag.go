@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/charlieegan3/gpxif/internal/pkg/config"
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
 	"github.com/charlieegan3/gpxif/internal/pkg/gpxfetch"
+	"github.com/charlieegan3/gpxif/internal/pkg/plan"
+	"github.com/charlieegan3/gpxif/internal/pkg/report"
 	"github.com/charlieegan3/gpxif/internal/pkg/utils"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -18,7 +25,7 @@ import (
 
 // tagCmd represents the tag command
 var tagCmd = &cobra.Command{
-	Use:   "tag",
+	Use:   "tag [files or directories...]",
 	Short: "tag takes GPX data and images and adds EXIF data to the images using UTC timestamps as a cross reference",
 	Run: func(cmd *cobra.Command, args []string) {
 		dryRun, err := cmd.Flags().GetBool("dry-run")
@@ -37,10 +44,89 @@ var tagCmd = &cobra.Command{
 			log.Fatalf("Failed to get auto flag: %s", err)
 		}
 
+		datasetOpts, err := getDatasetOptions(cmd)
+		if err != nil {
+			log.Fatalf("Failed to configure GPX matching: %s", err)
+		}
+
+		gpsOpts, err := getGPSOptions(cmd)
+		if err != nil {
+			log.Fatalf("Failed to configure GPS updates: %s", err)
+		}
+
+		motionOpts, err := getMotionOptions(cmd)
+		if err != nil {
+			log.Fatalf("Failed to configure motion updates: %s", err)
+		}
+
+		planOut, err := cmd.Flags().GetString("plan-out")
+		if err != nil {
+			log.Fatalf("Failed to get plan-out flag: %s", err)
+		}
+
+		continueOnError, err := cmd.Flags().GetBool("continue-on-error")
+		if err != nil {
+			log.Fatalf("Failed to get continue-on-error flag: %s", err)
+		}
+
+		failOnNames, err := cmd.Flags().GetStringSlice("fail-on")
+		if err != nil {
+			log.Fatalf("Failed to get fail-on flag: %s", err)
+		}
+		var failOn []report.Outcome
+		for _, name := range failOnNames {
+			outcome, err := report.ParseOutcome(name)
+			if err != nil {
+				log.Fatalf("Invalid fail-on flag: %s", err)
+			}
+			failOn = append(failOn, outcome)
+		}
+
+		outputName, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("Failed to get output flag: %s", err)
+		}
+		format, err := report.ParseFormat(outputName)
+		if err != nil {
+			log.Fatalf("Invalid output flag: %s", err)
+		}
+
+		// in the JSON formats only records are written to stdout, other messages go to stderr
+		var out io.Writer = os.Stdout
+		var records *report.RecordWriter
+		if format != report.FormatText {
+			out = os.Stderr
+			records = report.NewRecordWriter(os.Stdout, format)
+		}
+
+		jobs, err := cmd.Flags().GetInt("jobs")
+		if err != nil {
+			log.Fatalf("Failed to get jobs flag: %s", err)
+		}
+		if jobs < 1 {
+			log.Fatalf("Invalid jobs flag: must be at least 1")
+		}
+
+		exec, err := newExecutor(cmd)
+		if err != nil {
+			log.Fatalf("Failed to configure execution: %s", err)
+		}
+
+		var paths []string
+		if imageSource != "" {
+			paths = append(paths, imageSource)
+		}
+		paths = append(paths, args...)
+
+		files, err := discoverFiles(cmd, paths)
+		if err != nil {
+			log.Fatalf("Failed to find images: %s", err)
+		}
+
 		var g *gpx.GPXDataset
 
 		if autoSource {
-			path, err := homedir.Expand("~/.gpxif")
+			path, err := homedir.Expand(configPath)
 			if err != nil {
 				log.Fatalf("error expanding homedir: %v", err)
 			}
@@ -50,11 +136,11 @@ var tagCmd = &cobra.Command{
 				log.Fatalf("failed to load config: %s", err)
 			}
 
-			fmt.Println("Auto sourcing GPX data")
-			fmt.Println("GPX Source:", cfg.GPXSource.URLTemplate)
-			fmt.Println("GPX Source Username:", cfg.GPXSource.Username)
+			fmt.Fprintln(out, "Auto sourcing GPX data")
+			fmt.Fprintln(out, "GPX Source:", cfg.GPXSource.URLTemplate)
+			fmt.Fprintln(out, "GPX Source Username:", cfg.GPXSource.Username)
 
-			autoDs, err := gpxfetch.ForImages(cfg, imageSource)
+			autoDs, err := gpxfetch.ForImages(cfg, files)
 			if err != nil {
 				log.Fatalf("failed to auto source gpx data: %s", err)
 			}
@@ -65,70 +151,354 @@ var tagCmd = &cobra.Command{
 				log.Fatalf("Failed to get gpxSource flag: %s", err)
 			}
 
-			fileDs, err := gpx.NewGPXDatasetFromDisk(gpxSource)
+			fileDs, err := gpx.NewGPXDatasetFromDiskWithOptions(datasetOpts.read, gpxSource)
 			if err != nil {
 				log.Fatalf("Failed to create GPX dataset: %s", err)
 			}
 			g = &fileDs
 		}
+		datasetOpts.apply(g)
 
-		fmt.Println("Dry Run: ", dryRun)
-		fmt.Println("Image Source: ", imageSource)
-		fmt.Println("---")
-
-		files, err := os.ReadDir(imageSource)
-		if err != nil {
-			log.Fatalf("Failed to list files in images directory: %s", err)
+		fmt.Fprintln(out, "Dry Run: ", dryRun)
+		if planOut != "" {
+			fmt.Fprintln(out, "Plan Output: ", planOut)
+		}
+		if imageSource != "" {
+			fmt.Fprintln(out, "Image Source: ", imageSource)
+		}
+		fmt.Fprintln(out, "Images: ", len(files))
+		fmt.Fprintln(out, "Interpolation: ", g.Interpolation)
+		fmt.Fprintln(out, "Max Gap: ", g.MaxGap)
+		if datasetOpts.read.MaxAccuracy > 0 {
+			fmt.Fprintln(out, "Max Accuracy: ", datasetOpts.read.MaxAccuracy)
 		}
+		fmt.Fprintln(out, "GPS Policy: ", gpsOpts.Policy)
+		if gpsOpts.Policy == operations.GPSPolicyIfFar {
+			fmt.Fprintln(out, "GPS Threshold: ", gpsOpts.Threshold)
+		}
+		fmt.Fprintln(out, "Motion: ", motionOpts != nil)
+		fmt.Fprintln(out, "Jobs: ", jobs)
+		fmt.Fprintln(out, "---")
 
-		for _, f := range files {
-			if !utils.IsJPEGFile(f.Name()) {
-				fmt.Println(f.Name(), "skipped")
-				continue
-			}
+		t := tagger{g: g, check: checkOptions{gps: gpsOpts, motion: motionOpts}, dryRun: dryRun, plan: planOut != "", records: records != nil, exec: exec}
 
-			var ops []operations.Operation
+		var p plan.Plan
+		var rep report.Report
 
-			gpsOperations, err := operations.CheckGPSData(imageSource+"/"+f.Name(), g)
-			if err != nil {
-				log.Fatalf("failed to determine GPS operations for %s: %s", f.Name(), err)
-			}
-			ops = append(ops, gpsOperations...)
+		results := make([]*tagResult, len(files))
+		forEachOrdered(
+			len(files),
+			jobs,
+			func(i int) {
+				results[i] = t.tag(displayName(imageSource, files[i]), files[i])
+			},
+			func(i int) bool {
+				r := results[i]
+				results[i] = nil
 
-			timeOperations, err := operations.CheckLocalTime(imageSource+"/"+f.Name(), g)
+				if records != nil {
+					err := records.Write(r.record)
+					if err != nil {
+						log.Fatalf("failed to write output: %s", err)
+					}
+				} else {
+					_, err := io.Copy(out, &r.output)
+					if err != nil {
+						log.Fatalf("failed to write output: %s", err)
+					}
+				}
+
+				rep.Add(r.image, r.outcome, r.err)
+				if r.planned != nil {
+					p.Images = append(p.Images, *r.planned)
+				}
+
+				// processing stops at the first error unless continuing on errors
+				return r.outcome != report.OutcomeError || continueOnError
+			},
+		)
+
+		if records != nil {
+			err = records.Close()
 			if err != nil {
-				log.Fatalf("failed to determine local time operations for %s: %s", f.Name(), err)
+				log.Fatalf("failed to write output: %s", err)
 			}
-			ops = append(ops, timeOperations...)
+		}
+
+		exec.close(out)
 
-			// TODO: these need to be last since they depend on data set in other operations
-			modTimeOperations, err := operations.CheckModTime(imageSource + "/" + f.Name())
+		if planOut != "" {
+			err = plan.Write(planOut, p)
 			if err != nil {
-				log.Fatalf("failed to determine mtime operations for %s: %s", f.Name(), err)
+				log.Fatalf("failed to write plan: %s", err)
 			}
-			ops = append(ops, modTimeOperations...)
+			fmt.Fprintln(out, "---")
+			fmt.Fprintln(out, "Plan for", len(p.Images), "images written to", planOut)
+			fmt.Fprintln(out, "Run 'gpxif apply", planOut+"' to make these changes")
+		}
 
-			if len(ops) == 0 {
-				continue
-			}
+		fmt.Fprintln(out, "---")
+		err = rep.Summary(out)
+		if err != nil {
+			log.Fatalf("failed to write summary: %s", err)
+		}
 
-			fmt.Println("Updates to", f.Name())
+		if rep.Any(failOn) || (rep.Count(report.OutcomeError) > 0 && !continueOnError) {
+			os.Exit(1)
+		}
+	},
+}
 
-			for _, op := range ops {
-				fmt.Printf("  %s\n", op.Reason)
-				for k, v := range op.Fields {
-					fmt.Printf("    Set %q to %v\n", k, v)
-				}
+// tagger updates images using GPX data
+type tagger struct {
+	g      *gpx.GPXDataset
+	check  checkOptions
+	dryRun bool
+	// plan is set when the operations are to be planned rather than executed
+	plan bool
+	// records is set when results are output as records, which need more details of
+	// each image to be gathered
+	records bool
+	exec    *executor
+}
 
-				if !dryRun {
-					err := op.Execute(imageSource + "/" + f.Name())
-					if err != nil {
-						log.Fatalf("failed operation: %s", err)
-					}
-				}
+// tagResult is the outcome of tagging a single file and the output to show for it
+type tagResult struct {
+	image   string
+	outcome report.Outcome
+	err     error
+	// planned is set when planning and the image needs updates
+	planned *plan.Image
+	output  bytes.Buffer
+	record  report.Record
+}
+
+// tag checks and updates a single file, it's safe to call concurrently for different files
+func (t *tagger) tag(name, image string) *tagResult {
+	r := &tagResult{image: image}
+	defer r.complete()
+
+	if !utils.IsImageFile(name) {
+		fmt.Fprintln(&r.output, name, "skipped")
+		r.outcome = report.OutcomeUnsupported
+		return r
+	}
+
+	if t.records {
+		describeImage(image, t.g, &r.record)
+	}
+
+	ops, decision, err := checkImage(image, t.g, t.check)
+	if err != nil {
+		r.outcome, r.err = outcomeForError(err), err
+		fmt.Fprintln(&r.output, name, r.outcome+":", err)
+		return r
+	}
+	r.record.GPS = &report.GPSDecision{Action: string(decision.Action), Reason: decision.Reason}
+	r.record.Distance = decision.Distance
+
+	// replacements are shown with the operations, kept GPS data is shown here with
+	// its distance from the GPX position
+	if decision.Action == operations.GPSActionKeep && decision.Distance != nil {
+		fmt.Fprintf(&r.output, "%s: %s\n", name, decision.Reason)
+	}
+
+	if len(ops) == 0 {
+		r.outcome = report.OutcomeAlreadyCorrect
+		return r
+	}
+
+	fmt.Fprintln(&r.output, "Updates to", name)
+
+	for _, op := range ops {
+		fmt.Fprintf(&r.output, "  %s\n", op.Reason)
+		switch {
+		case op.Interpolation == "":
+		case op.Interpolation != gpx.InterpolationNearest:
+			fmt.Fprintf(&r.output, "    Position from %s interpolation\n", op.Interpolation)
+		case t.g.Interpolation != "" && t.g.Interpolation != gpx.InterpolationNearest:
+			// the points either side were in different segments, too far apart in time
+			// or the time was at the edge of the track
+			fmt.Fprintln(&r.output, "    Position from the nearest recorded point, it couldn't be interpolated")
+		}
+		for _, c := range op.Changes() {
+			fmt.Fprintf(&r.output, "    Set %q to %v\n", c.Key, c.Value)
+		}
+	}
+
+	status := report.StatusPlanned
+	if t.records {
+		// the previous values must be read before the operations are run
+		r.record.Operations, err = operationRecords(image, ops)
+		if err != nil {
+			r.outcome, r.err = report.OutcomeError, err
+			fmt.Fprintln(&r.output, name, r.outcome+":", err)
+			return r
+		}
+	}
+
+	if t.plan {
+		var planned plan.Image
+		planned, err = plan.NewImage(image, ops)
+		if err != nil {
+			err = fmt.Errorf("failed to plan: %w", err)
+		}
+		r.planned = &planned
+	} else if !t.dryRun {
+		status = report.StatusApplied
+		err = t.exec.execute(&r.output, image, ops)
+	}
+	if err != nil {
+		status = report.StatusFailed
+		r.outcome, r.err, r.planned = report.OutcomeError, err, nil
+		fmt.Fprintln(&r.output, name, r.outcome+":", err)
+	} else {
+		r.outcome = report.OutcomeTagged
+	}
+
+	for i := range r.record.Operations {
+		r.record.Operations[i].Status = status
+	}
+
+	return r
+}
+
+// complete copies the outcome of the result to its record
+func (r *tagResult) complete() {
+	r.record.Path = r.image
+	r.record.Outcome = r.outcome
+	if r.err != nil {
+		r.record.Error = r.err.Error()
+	}
+}
+
+// describeImage sets the image's time and matched GPX point on the record. Errors are
+// ignored as they're reported when checking the image.
+func describeImage(image string, g *gpx.GPXDataset, record *report.Record) {
+	utcTime, err := exif.GetUTC(image)
+	if err != nil {
+		return
+	}
+	record.UTCTime = &utcTime
+
+	match, err := g.Match(utcTime)
+	if err != nil {
+		return
+	}
+	record.Match = &report.Match{
+		Latitude:    match.Point.Latitude,
+		Longitude:   match.Point.Longitude,
+		Method:      string(match.Method),
+		NearestTime: match.Nearest.Timestamp,
+		TimeDelta:   utcTime.Sub(match.Nearest.Timestamp).Seconds(),
+	}
+	if match.Point.Elevation.NotNull() {
+		elevation := match.Point.Elevation.Value()
+		record.Match.Elevation = &elevation
+	}
+}
+
+// operationRecords returns records of the operations with the image's current values
+func operationRecords(image string, ops []operations.Operation) ([]report.Operation, error) {
+	var records []report.Operation
+
+	for _, op := range ops {
+		changes := op.Changes()
+
+		previous, err := exif.Inverse(image, changes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current values: %w", err)
+		}
+
+		record := report.Operation{
+			Reason:  op.Reason,
+			ModTime: op.ModTime,
+			Changes: make([]report.Change, len(changes)),
+		}
+		for i, c := range changes {
+			record.Changes[i] = report.Change{
+				IFDPath: c.IFDPath,
+				Key:     c.Key,
+				Old:     exif.Value{V: previous[i].Value},
+				New:     exif.Value{V: c.Value},
 			}
 		}
-	},
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// displayName returns the name to show for a file, files in the images directory are
+// shown relative to it and others as they were given
+func displayName(imageSource, file string) string {
+	if imageSource == "" {
+		return file
+	}
+
+	rel, err := filepath.Rel(imageSource, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return file
+	}
+
+	return rel
+}
+
+// checkOptions control which updates are made to images
+type checkOptions struct {
+	gps operations.GPSOptions
+	// motion is nil when motion isn't written
+	motion *operations.MotionOptions
+}
+
+// checkImage returns the operations needed to update the image using the GPX data, and
+// what was decided for the image's GPS data
+func checkImage(image string, g *gpx.GPXDataset, opts checkOptions) ([]operations.Operation, operations.GPSDecision, error) {
+	var ops []operations.Operation
+
+	gpsOperations, decision, err := operations.CheckGPSData(image, g, opts.gps)
+	if err != nil {
+		return nil, decision, fmt.Errorf("failed to determine GPS operations: %w", err)
+	}
+	ops = append(ops, gpsOperations...)
+
+	// motion is only written with a new position, so it's not mixed with the motion
+	// recorded by the camera for a kept position
+	if opts.motion != nil && decision.Action != operations.GPSActionKeep {
+		motionOperations, err := operations.CheckMotion(image, g, *opts.motion)
+		if err != nil {
+			return nil, decision, fmt.Errorf("failed to determine motion operations: %w", err)
+		}
+		ops = append(ops, motionOperations...)
+	}
+
+	timeOperations, err := operations.CheckLocalTime(image, g)
+	if err != nil {
+		return nil, decision, fmt.Errorf("failed to determine local time operations: %w", err)
+	}
+	ops = append(ops, timeOperations...)
+
+	// TODO: these need to be last since they depend on data set in other operations
+	modTimeOperations, err := operations.CheckModTime(image)
+	if err != nil {
+		return nil, decision, fmt.Errorf("failed to determine mtime operations: %w", err)
+	}
+	ops = append(ops, modTimeOperations...)
+
+	return ops, decision, nil
+}
+
+// outcomeForError returns the outcome to report for an image which couldn't be checked
+func outcomeForError(err error) report.Outcome {
+	var noFixErr *gpx.NoFixError
+	switch {
+	case errors.As(err, &noFixErr):
+		return report.OutcomeNoFix
+	case errors.Is(err, exif.ErrNoExif):
+		return report.OutcomeUnsupported
+	}
+
+	return report.OutcomeError
 }
 
 func init() {
@@ -144,20 +514,47 @@ func init() {
 		false,
 		"Automatically determine the GPX data based on image timestamps",
 	)
+	addDatasetFlags(tagCmd)
+	addGPSFlags(tagCmd)
+	tagCmd.Flags().String(
+		"plan-out",
+		"",
+		"Write the planned changes to this JSON or YAML file for 'gpxif apply' instead of updating images",
+	)
+	tagCmd.Flags().Bool(
+		"continue-on-error",
+		false,
+		"Keep processing the remaining images when one fails, by default processing stops at the first failure",
+	)
+	tagCmd.Flags().StringSlice(
+		"fail-on",
+		nil,
+		"Exit with a non-zero code if any image has one of these outcomes: tagged, already-correct, no-fix, unsupported or error",
+	)
+	tagCmd.Flags().StringP(
+		"output",
+		"o",
+		string(report.FormatText),
+		"Output format: text, json or ndjson. The JSON formats write a record for each image to stdout and other messages to stderr",
+	)
+	tagCmd.Flags().IntP(
+		"jobs",
+		"j",
+		1,
+		"Number of images to process at once, output is still shown in file order",
+	)
+	addDiscoveryFlags(tagCmd)
+	addExecutorFlags(tagCmd)
 	tagCmd.Flags().StringP(
 		"images",
 		"i",
 		"",
-		"Directory containing images to tag",
+		"Directory containing images to tag, files and directories can also be given as arguments",
 	)
 	tagCmd.Flags().StringP(
 		"gpx",
 		"g",
 		"",
-		"GPX file containing timestamps",
+		"GPX or other supported location history file, or a directory of them, containing timestamps",
 	)
-	err := tagCmd.MarkFlagRequired("images")
-	if err != nil {
-		log.Fatalf("Failed to mark images flag required: %s", err)
-	}
 }
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/journal"
+)
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo <journal>",
+	Short: "undo restores the EXIF values and mtimes of images changed in the run that wrote the journal",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := journal.Load(args[0])
+		if err != nil {
+			log.Fatalf("Failed to load journal: %s", err)
+		}
+
+		failed := false
+
+		// entries are undone newest first in case a file was changed more than once
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+
+			err := journal.Undo(e)
+			if errors.Is(err, journal.ErrModified) {
+				fmt.Println(e.Path, "refused:", err)
+				failed = true
+				continue
+			}
+			if err != nil {
+				fmt.Println(e.Path, "failed:", err)
+				failed = true
+				continue
+			}
+
+			fmt.Println(e.Path, "restored")
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
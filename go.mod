@@ -3,7 +3,6 @@ module github.com/charlieegan3/gpxif
 go 1.18
 
 require (
-	github.com/av-elier/go-decimal-to-rational v0.0.0-20191127152832-89e6aad02ecf
 	github.com/djherbis/times v1.5.0
 	github.com/dsoprea/go-exif/v3 v3.0.0-20210625224831-a6301f85c82b
 	github.com/dsoprea/go-jpeg-image-structure/v2 v2.0.0-20210512043942-b434301c6836
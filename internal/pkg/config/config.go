@@ -8,6 +8,7 @@ import (
 
 type Config struct {
 	GPXSource GPXSource `yaml:"gpx_source"`
+	CSV       CSV       `yaml:"csv"`
 }
 
 type GPXSource struct {
@@ -16,6 +17,15 @@ type GPXSource struct {
 	Password    string `yaml:"password"`
 }
 
+// CSV sets the columns read from CSV files of points and the format of their times
+type CSV struct {
+	Lat        string `yaml:"lat"`
+	Lon        string `yaml:"lon"`
+	Ele        string `yaml:"ele"`
+	Time       string `yaml:"time"`
+	TimeFormat string `yaml:"time_format"`
+}
+
 func Load(configFile string) (Config, error) {
 	var cfg Config
 	var err error
@@ -21,6 +21,18 @@ func TestLoad(t *testing.T) {
 				},
 			},
 		},
+		"csv columns": {
+			ConfigFile: "./fixtures/csv.yaml",
+			Expected: Config{
+				CSV: CSV{
+					Lat:        "Y",
+					Lon:        "X",
+					Ele:        "Height (m)",
+					Time:       "Recorded At",
+					TimeFormat: "02/01/2006 15:04:05",
+				},
+			},
+		},
 	}
 
 	for name, testCase := range testCases {
@@ -0,0 +1,251 @@
+package discover
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Options controls which files are found
+type Options struct {
+	// Recursive includes files in subdirectories
+	Recursive bool
+	// Include, when set, limits files to those matching at least one of the patterns
+	Include []string
+	// Exclude skips files and directories matching any of the patterns
+	Exclude []string
+	// FollowSymlinks searches symlinked directories, by default they're skipped.
+	// Symlinked files are always included.
+	FollowSymlinks bool
+}
+
+// Validate checks that the options' patterns are valid.
+//
+// Patterns use the syntax of path.Match. Patterns without a slash are matched against
+// file and directory names, other patterns are matched against the slash separated path
+// relative to the directory being searched where "**" matches any number of directories.
+func (o Options) Validate() error {
+	for _, patterns := range [][]string{o.Include, o.Exclude} {
+		for _, pattern := range patterns {
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Paths returns the files for a list of paths. Files are always included, directories
+// are searched for files matching the options. Files are returned in the order of the
+// paths, and only once however many times they're found.
+func Paths(paths []string, opts Options) ([]string, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	w := walker{opts: opts, seen: make(map[string]bool)}
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s: %w", p, err)
+		}
+
+		first, err := w.visit(p)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			continue
+		}
+
+		switch {
+		case info.IsDir():
+			err = w.walk(p, "")
+			if err != nil {
+				return nil, err
+			}
+		case info.Mode().IsRegular():
+			w.files = append(w.files, p)
+		default:
+			return nil, fmt.Errorf("%s is not a file or directory", p)
+		}
+	}
+
+	return w.files, nil
+}
+
+// ReadList reads a list of paths, one per line or separated by NUL characters as
+// written by find -print0. Empty entries are ignored.
+func ReadList(r io.Reader) ([]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read list: %w", err)
+	}
+
+	sep := "\n"
+	if bytes.IndexByte(b, 0) >= 0 {
+		sep = "\x00"
+	}
+
+	var paths []string
+	for _, p := range strings.Split(string(b), sep) {
+		if sep == "\n" {
+			p = strings.TrimSuffix(p, "\r")
+		}
+		if p == "" {
+			continue
+		}
+		paths = append(paths, p)
+	}
+
+	return paths, nil
+}
+
+type walker struct {
+	opts Options
+	// seen holds the resolved paths of the directories and files found so that those
+	// reached more than once through symlinks are only used once
+	seen  map[string]bool
+	files []string
+}
+
+func (w *walker) walk(dir, rel string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list files in %s: %w", dir, err)
+	}
+
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		r := path.Join(rel, e.Name())
+
+		mode := e.Type()
+		if mode&fs.ModeSymlink != 0 {
+			info, err := os.Stat(p)
+			if err != nil {
+				// broken links are skipped
+				continue
+			}
+			mode = info.Mode().Type()
+
+			if mode.IsDir() && !w.opts.FollowSymlinks {
+				continue
+			}
+		}
+
+		if matchAny(w.opts.Exclude, r) {
+			continue
+		}
+
+		switch {
+		case mode.IsDir():
+			if !w.opts.Recursive {
+				continue
+			}
+
+			first, err := w.visit(p)
+			if err != nil {
+				return err
+			}
+			if !first {
+				continue
+			}
+
+			err = w.walk(p, r)
+			if err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			if len(w.opts.Include) > 0 && !matchAny(w.opts.Include, r) {
+				continue
+			}
+
+			first, err := w.visit(p)
+			if err != nil {
+				return err
+			}
+			if !first {
+				continue
+			}
+
+			w.files = append(w.files, p)
+		}
+	}
+
+	return nil
+}
+
+// visit records the path as seen and returns true if it had not been seen before
+func (w *walker) visit(p string) (bool, error) {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve %s: %w", p, err)
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return false, fmt.Errorf("failed to get absolute path for %s: %w", p, err)
+	}
+
+	if w.seen[resolved] {
+		return false, nil
+	}
+	w.seen[resolved] = true
+
+	return true, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if match(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match reports whether the slash separated path name matches the pattern, see
+// Options.Validate for the pattern syntax
+func match(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		ok, _ := path.Match(pattern[0], name[0])
+		if !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
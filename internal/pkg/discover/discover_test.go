@@ -0,0 +1,225 @@
+package discover
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathsDirectory(t *testing.T) {
+	root := createTree(t)
+
+	testCases := map[string]struct {
+		Options       Options
+		ExpectedFiles []string
+		ExpectError   bool
+	}{
+		"flat includes symlinked files": {
+			ExpectedFiles: []string{"a.jpg", "b.txt", "ext.jpg", "link.jpg"},
+		},
+		"recursive skips symlinked directories and files already found": {
+			Options: Options{Recursive: true},
+			ExpectedFiles: []string{
+				".thumbnails/e.jpg",
+				"2022/08/03/c.JPG",
+				"2022/08/03/d.heic",
+				"a.jpg",
+				"b.txt",
+				"ext.jpg",
+			},
+		},
+		"exclude names and directories": {
+			Options: Options{Recursive: true, Exclude: []string{".thumbnails", "*.txt"}},
+			ExpectedFiles: []string{
+				"2022/08/03/c.JPG",
+				"2022/08/03/d.heic",
+				"a.jpg",
+				"ext.jpg",
+			},
+		},
+		"include names": {
+			Options:       Options{Recursive: true, Include: []string{"*.JPG", "*.heic"}},
+			ExpectedFiles: []string{"2022/08/03/c.JPG", "2022/08/03/d.heic"},
+		},
+		"include paths": {
+			Options:       Options{Recursive: true, Include: []string{"2022/**/*.heic"}},
+			ExpectedFiles: []string{"2022/08/03/d.heic"},
+		},
+		"follow symlinks recursively skips files and directories already found": {
+			Options: Options{Recursive: true, FollowSymlinks: true},
+			ExpectedFiles: []string{
+				".thumbnails/e.jpg",
+				"2022/08/03/c.JPG",
+				"2022/08/03/d.heic",
+				"a.jpg",
+				"b.txt",
+				"ext.jpg",
+				"external/g.jpg",
+			},
+		},
+		"invalid pattern": {
+			Options:     Options{Include: []string{"["}},
+			ExpectError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			files, err := Paths([]string{root}, testCase.Options)
+			if testCase.ExpectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var expected []string
+			for _, f := range testCase.ExpectedFiles {
+				expected = append(expected, filepath.Join(root, filepath.FromSlash(f)))
+			}
+			assert.Equal(t, expected, files)
+		})
+	}
+}
+
+func TestPaths(t *testing.T) {
+	root := createTree(t)
+
+	testCases := map[string]struct {
+		Paths         []string
+		Options       Options
+		ExpectedFiles []string
+		ExpectError   bool
+	}{
+		"files and directories": {
+			Paths:   []string{"b.txt", "2022/08/03", "a.jpg"},
+			Options: Options{Include: []string{"*.jpg", "*.JPG"}},
+			ExpectedFiles: []string{
+				"b.txt",
+				"2022/08/03/c.JPG",
+				"a.jpg",
+			},
+		},
+		"files are only listed once": {
+			Paths:         []string{"a.jpg", "2022/08/03/c.JPG", "a.jpg", "link.jpg"},
+			ExpectedFiles: []string{"a.jpg", "2022/08/03/c.JPG"},
+		},
+		"missing file": {
+			Paths:       []string{"a.jpg", "missing.jpg"},
+			ExpectError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+			for _, p := range testCase.Paths {
+				paths = append(paths, filepath.Join(root, filepath.FromSlash(p)))
+			}
+
+			files, err := Paths(paths, testCase.Options)
+			if testCase.ExpectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var expected []string
+			for _, f := range testCase.ExpectedFiles {
+				expected = append(expected, filepath.Join(root, filepath.FromSlash(f)))
+			}
+			assert.Equal(t, expected, files)
+		})
+	}
+}
+
+func TestReadList(t *testing.T) {
+	testCases := map[string]struct {
+		Input    string
+		Expected []string
+	}{
+		"lines": {
+			Input:    "a.jpg\nb c.jpg\r\n\nd.jpg",
+			Expected: []string{"a.jpg", "b c.jpg", "d.jpg"},
+		},
+		"nul separated": {
+			Input:    "a.jpg\x00with\nnewline.jpg\x00",
+			Expected: []string{"a.jpg", "with\nnewline.jpg"},
+		},
+		"empty": {
+			Input:    "",
+			Expected: nil,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			paths, err := ReadList(strings.NewReader(testCase.Input))
+			require.NoError(t, err)
+			assert.Equal(t, testCase.Expected, paths)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testCases := map[string]struct {
+		Pattern  string
+		Name     string
+		Expected bool
+	}{
+		"name":                     {Pattern: "*.jpg", Name: "2022/08/a.jpg", Expected: true},
+		"name is case sensitive":   {Pattern: "*.jpg", Name: "2022/08/a.JPG", Expected: false},
+		"path":                     {Pattern: "2022/*/a.jpg", Name: "2022/08/a.jpg", Expected: true},
+		"path must match fully":    {Pattern: "2022/*", Name: "2022/08/a.jpg", Expected: false},
+		"double star":              {Pattern: "2022/**", Name: "2022/08/a.jpg", Expected: true},
+		"double star matches none": {Pattern: "2022/**/a.jpg", Name: "2022/a.jpg", Expected: true},
+		"double star in middle":    {Pattern: "**/08/*.jpg", Name: "2022/08/a.jpg", Expected: true},
+		"double star no match":     {Pattern: "**/09/*.jpg", Name: "2022/08/a.jpg", Expected: false},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testCase.Expected, match(testCase.Pattern, testCase.Name))
+		})
+	}
+}
+
+// createTree creates a directory of files to search, including links to a directory
+// outside of it
+func createTree(t *testing.T) string {
+	t.Helper()
+
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+
+	for _, f := range []string{
+		"root/a.jpg",
+		"root/b.txt",
+		"root/2022/08/03/c.JPG",
+		"root/2022/08/03/d.heic",
+		"root/.thumbnails/e.jpg",
+		"outside/g.jpg",
+		"outside/h.jpg",
+	} {
+		p := filepath.Join(base, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(f), 0644))
+	}
+
+	for link, target := range map[string]string{
+		"link.jpg": filepath.Join(root, "2022", "08", "03", "c.JPG"),
+		"linkdir":  filepath.Join(root, "2022", "08"),
+		"loop":     root,
+		"external": outside,
+		"ext.jpg":  filepath.Join(outside, "h.jpg"),
+		"broken":   filepath.Join(outside, "missing.jpg"),
+	} {
+		require.NoError(t, os.Symlink(target, filepath.Join(root, link)))
+	}
+
+	return root
+}
@@ -1,32 +1,90 @@
 package exif
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	jpegstructure "github.com/dsoprea/go-jpeg-image-structure/v2"
+	"io"
 	"io/ioutil"
 	"math"
-	"os"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charlieegan3/gpxif/internal/pkg/utils"
 	"github.com/dsoprea/go-exif/v3"
 	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	exifundefined "github.com/dsoprea/go-exif/v3/undefined"
 )
 
-// GetKey extracts an abitrary key from the image's EXIF data
-func GetKey(image, targetIFDPath, key string) (interface{}, error) {
-	jmp := jpegstructure.NewJpegMediaParser()
+// ErrNoExif is returned when an image has no EXIF data
+var ErrNoExif = exif.ErrNoExif
+
+// ErrTagNotFound is returned when reading a tag which is not set
+var ErrTagNotFound = exif.ErrTagNotFound
+
+// container is an image format holding EXIF data which can be read and replaced,
+// it's implemented by jpegstructure.SegmentList for JPEGs and heifImage for HEIFs
+type container interface {
+	// Exif returns the root IFD and raw data of the image's EXIF data
+	Exif() (*exif.Ifd, []byte, error)
+	// ConstructExifBuilder returns a builder preloaded with the image's existing tags
+	ConstructExifBuilder() (*exif.IfdBuilder, error)
+	// SetExif replaces the image's EXIF data with the tags in the builder
+	SetExif(ib *exif.IfdBuilder) error
+	// Write writes the image, including any EXIF changes, to w
+	Write(w io.Writer) error
+}
+
+// parseImage loads the image file as a container based on its contents
+func parseImage(image string) (container, error) {
+	b, err := ioutil.ReadFile(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	if isHEIF(b) {
+		h, err := parseHEIF(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse heif image: %w", err)
+		}
+		return h, nil
+	}
 
-	intfc, err := jmp.ParseFile(image)
+	intfc, err := jpegstructure.NewJpegMediaParser().ParseBytes(b)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse image: %s", err)
 	}
 
+	return intfc.(*jpegstructure.SegmentList), nil
+}
+
+// extractExif returns the raw EXIF data, starting at the tiff header, from image data.
+// The Exif item is used for HEIF images, other formats are searched for the EXIF data.
+func extractExif(b []byte) ([]byte, error) {
+	if isHEIF(b) {
+		h, err := parseHEIF(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse heif image: %w", err)
+		}
+		return h.tiffData()
+	}
+
+	return exif.SearchAndExtractExif(b)
+}
+
+// GetKey extracts an abitrary key from the image's EXIF data
+func GetKey(image, targetIFDPath, key string) (interface{}, error) {
+	intfc, err := parseImage(image)
+	if err != nil {
+		return nil, err
+	}
+
 	rootIfd, _, err := intfc.Exif()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get root ifd: %s", err)
+		return nil, fmt.Errorf("failed to get root ifd: %w", err)
 	}
 
 	_, it, err := getIndexedTagFromName(key)
@@ -43,7 +101,7 @@ func GetKey(image, targetIFDPath, key string) (interface{}, error) {
 			}
 
 			if len(results) > 0 {
-				retValue, err = results[0].Value()
+				retValue, err = tagValue(results[0], c.ByteOrder())
 				if err != nil {
 					return "", fmt.Errorf("failed to get value for key %s: %w", key, err)
 				}
@@ -54,23 +112,177 @@ func GetKey(image, targetIFDPath, key string) (interface{}, error) {
 	return retValue, nil
 }
 
-// SetKey sets a key value of Ascii or []Rational in the exif data at the specified path
-func SetKey(image, targetIFDPath, key string, value any) error {
-	jmp := jpegstructure.NewJpegMediaParser()
+// getKeys reads several keys from one of the image's IFDs, parsing the image only once.
+// Keys which are not set are missing from the result.
+func getKeys(image, targetIFDPath string, keys ...string) (map[string]interface{}, error) {
+	intfc, err := parseImage(image)
+	if err != nil {
+		return nil, err
+	}
 
-	intfc, err := jmp.ParseFile(image)
+	rootIfd, _, err := intfc.Exif()
 	if err != nil {
-		return fmt.Errorf("failed to parse image: %s", err)
+		return nil, fmt.Errorf("failed to get root ifd: %w", err)
 	}
 
-	sl := intfc.(*jpegstructure.SegmentList)
+	values := make(map[string]interface{})
+	for _, c := range rootIfd.Children() {
+		if c.IfdIdentity().String() != targetIFDPath {
+			continue
+		}
+
+		for _, key := range keys {
+			_, it, err := getIndexedTagFromName(key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to lookup indexed tag from name: %w", err)
+			}
+
+			results, err := c.FindTagWithId(it.Id)
+			if errors.Is(err, exif.ErrTagNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to find tag %s: %w", key, err)
+			}
+
+			values[key], err = tagValue(results[0], c.ByteOrder())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get value for key %s: %w", key, err)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// tagValue returns the value of a tag entry. UNDEFINED values, which go-exif decodes to
+// tag specific types, are returned as Undefined so that they can be written back.
+func tagValue(ite *exif.IfdTagEntry, byteOrder binary.ByteOrder) (interface{}, error) {
+	value, err := ite.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	undefined, ok := value.(exifundefined.EncodeableValue)
+	if !ok {
+		return value, nil
+	}
+
+	encoded, _, err := exifundefined.Encode(undefined, byteOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode undefined value: %w", err)
+	}
+
+	return Undefined(encoded), nil
+}
+
+// Inverse returns the changes which restore the image's current values for the keys in
+// changes. Keys which are not currently set have a nil Value, so applying the returned
+// changes removes them again.
+func Inverse(image string, changes []Change) ([]Change, error) {
+	intfc, err := parseImage(image)
+	if err != nil {
+		return nil, err
+	}
+
+	rootIfd, _, err := intfc.Exif()
+	if err != nil && !errors.Is(err, exif.ErrNoExif) {
+		return nil, fmt.Errorf("failed to get root ifd: %s", err)
+	}
+
+	inverse := make([]Change, len(changes))
+	for i, c := range changes {
+		inverse[i] = Change{IFDPath: c.IFDPath, Key: c.Key}
+
+		_, it, err := getIndexedTagFromName(c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lookup indexed tag from name: %w", err)
+		}
+
+		if rootIfd == nil {
+			continue
+		}
+
+		ifd := rootIfd
+		if c.IFDPath != exifcommon.IfdStandardIfdIdentity.String() {
+			ifd = nil
+			for _, child := range rootIfd.Children() {
+				if child.IfdIdentity().String() == c.IFDPath {
+					ifd = child
+				}
+			}
+		}
+		if ifd == nil {
+			continue
+		}
+
+		results, err := ifd.FindTagWithId(it.Id)
+		if errors.Is(err, exif.ErrTagNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find tag %s: %w", c.Key, err)
+		}
+
+		inverse[i].Value, err = tagValue(results[0], ifd.ByteOrder())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get value for key %s: %w", c.Key, err)
+		}
+	}
+
+	return inverse, nil
+}
+
+// Undefined is a value of the UNDEFINED type, it's written to the image as is
+type Undefined []byte
+
+func (u Undefined) String() string {
+	return fmt.Sprintf("%q", string(u))
+}
+
+// CharacterCodeASCII returns text prefixed with the ASCII character code, as used by
+// UNDEFINED tags such as GPSProcessingMethod and UserComment
+func CharacterCodeASCII(text string) Undefined {
+	return Undefined("ASCII\x00\x00\x00" + text)
+}
+
+// Change is an update to a single key in an image's EXIF data
+type Change struct {
+	// IFDPath is the path to the IFD containing the key, e.g. IFD/GPSInfo
+	IFDPath string
+	// Key is the name of the tag to set
+	Key string
+	// Value is the new value, either a string for Ascii, []byte for Byte, []uint16 for
+	// Short, []Rational or Undefined. When nil, the key is removed.
+	Value any
+}
+
+// SetKey sets a key value of Ascii, Byte, Short, Rational or Undefined in the exif data at
+// the specified path
+func SetKey(image, targetIFDPath, key string, value any) error {
+	return Apply(image, []Change{{IFDPath: targetIFDPath, Key: key, Value: value}})
+}
+
+// Apply parses the image once, makes all the changes to its exif data and then writes
+// the image back to disk. Changes are made in order, so later changes to a key win.
+// Every write also removes the thumbnail offsets in IFD1 and the FileSource and
+// SceneType tags, which go-exif can't write back.
+func Apply(image string, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	sl, err := parseImage(image)
+	if err != nil {
+		return err
+	}
 
 	rootIb, err := sl.ConstructExifBuilder()
 	if err != nil {
 		return fmt.Errorf("failed to construct exif builder: %s", err)
 	}
 
-	rootIfd, _, err := intfc.Exif()
+	rootIfd, _, err := sl.Exif()
 	if err != nil {
 		return fmt.Errorf("failed to get root ifd: %s", err)
 	}
@@ -91,42 +303,65 @@ func SetKey(image, targetIFDPath, key string, value any) error {
 	childIb.DeleteAll(0xa301)
 	childIb.DeleteAll(0xa300)
 
-	// set the value we want to set
-	childIb, err = exif.GetOrCreateIbFromRootIb(rootIb, targetIFDPath)
-	if err != nil {
-		return fmt.Errorf("failed to get child ifd builder: %s", err)
-	}
+	enc := exifcommon.NewValueEncoder(rootIfd.ByteOrder())
 
-	_, it, err := getIndexedTagFromName(key)
-	if err != nil {
-		return fmt.Errorf("failed to lookup indexed tag from name: %s", err)
-	}
+	// set the values we want to set
+	for _, c := range changes {
+		childIb, err = exif.GetOrCreateIbFromRootIb(rootIb, c.IFDPath)
+		if err != nil {
+			return fmt.Errorf("failed to get child ifd builder: %s", err)
+		}
 
-	enc := exifcommon.NewValueEncoder(rootIfd.ByteOrder())
-	data, err := enc.Encode(value)
-	if err != nil {
-		return fmt.Errorf("failed to encode value: %s", err)
-	}
-
-	valueType := exifcommon.TypeAscii
-	switch value.(type) {
-	case string:
-		valueType = exifcommon.TypeAscii
-	case []exifcommon.Rational:
-		valueType = exifcommon.TypeRational
-	default:
-		return fmt.Errorf("unsupported value type: %s", reflect.TypeOf(value))
-	}
-
-	err = childIb.Set(exif.NewBuilderTag(
-		targetIFDPath,
-		it.Id,
-		valueType,
-		exif.NewIfdBuilderTagValueFromBytes(data.Encoded),
-		rootIfd.ByteOrder(),
-	))
-	if err != nil {
-		return fmt.Errorf("failed to set value: %s", err)
+		_, it, err := getIndexedTagFromName(c.Key)
+		if err != nil {
+			return fmt.Errorf("failed to lookup indexed tag from name: %s", err)
+		}
+
+		if c.Value == nil {
+			_, err = childIb.DeleteAll(it.Id)
+			if err != nil {
+				return fmt.Errorf("failed to remove %s: %s", c.Key, err)
+			}
+			continue
+		}
+
+		var valueType exifcommon.TagTypePrimitive
+		switch c.Value.(type) {
+		case string:
+			valueType = exifcommon.TypeAscii
+		case []byte:
+			valueType = exifcommon.TypeByte
+		case []uint16:
+			valueType = exifcommon.TypeShort
+		case []exifcommon.Rational:
+			valueType = exifcommon.TypeRational
+		case Undefined:
+			valueType = exifcommon.TypeUndefined
+		default:
+			return fmt.Errorf("unsupported value type for %s: %s", c.Key, reflect.TypeOf(c.Value))
+		}
+
+		var encoded []byte
+		if undefined, ok := c.Value.(Undefined); ok {
+			encoded = undefined
+		} else {
+			data, err := enc.Encode(c.Value)
+			if err != nil {
+				return fmt.Errorf("failed to encode value for %s: %s", c.Key, err)
+			}
+			encoded = data.Encoded
+		}
+
+		err = childIb.Set(exif.NewBuilderTag(
+			c.IFDPath,
+			it.Id,
+			valueType,
+			exif.NewIfdBuilderTagValueFromBytes(encoded),
+			rootIfd.ByteOrder(),
+		))
+		if err != nil {
+			return fmt.Errorf("failed to set value for %s: %s", c.Key, err)
+		}
 	}
 
 	// write the data back to the file
@@ -135,15 +370,12 @@ func SetKey(image, targetIFDPath, key string, value any) error {
 		return fmt.Errorf("failed to set exif data: %s", err)
 	}
 
-	f, err := os.Create(image)
+	err = utils.WriteFileAtomic(image, sl.Write)
 	if err != nil {
-		return fmt.Errorf("failed to get file handle for image: %s", err)
+		return fmt.Errorf("failed to write image: %w", err)
 	}
-	defer f.Close()
-
-	err = sl.Write(f)
 
-	return err
+	return nil
 }
 
 func SetLocalTime(image string, localTime time.Time) error {
@@ -153,17 +385,13 @@ func SetLocalTime(image string, localTime time.Time) error {
 	// TODO I think this value is meant to be in milliseconds
 	subSec := fmt.Sprintf("%d", localTime.Nanosecond()/1000000)
 
-	err := SetKey(image, "IFD/Exif", "DateTimeOriginal", dateTime)
-	if err != nil {
-		return fmt.Errorf("failed to set DateTimeOriginal: %v", err)
-	}
-	err = SetKey(image, "IFD/Exif", "SubSecTimeOriginal", subSec)
-	if err != nil {
-		return fmt.Errorf("failed to set SubSecTimeOriginal: %v", err)
-	}
-	err = SetKey(image, "IFD/Exif", "OffsetTimeOriginal", offset)
+	err := Apply(image, []Change{
+		{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: dateTime},
+		{IFDPath: "IFD/Exif", Key: "SubSecTimeOriginal", Value: subSec},
+		{IFDPath: "IFD/Exif", Key: "OffsetTimeOriginal", Value: offset},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to set OffsetTimeOriginal: %v", err)
+		return fmt.Errorf("failed to set local time: %v", err)
 	}
 
 	return nil
@@ -175,9 +403,9 @@ func GetUTC(image string) (time.Time, error) {
 		return time.Time{}, fmt.Errorf("failed to read image file: %w", err)
 	}
 
-	rawExif, err := exif.SearchAndExtractExif(b)
-	if err == exif.ErrNoExif {
-		return time.Time{}, fmt.Errorf("no exif data found")
+	rawExif, err := extractExif(b)
+	if errors.Is(err, exif.ErrNoExif) {
+		return time.Time{}, ErrNoExif
 	} else if err != nil {
 		return time.Time{}, fmt.Errorf("failed to get raw exif data: %s", err)
 	}
@@ -301,6 +529,41 @@ func RationalDegreesMinutesSecondsFromDecimal(decimal float64) []exifcommon.Rati
 	}
 }
 
+// RationalHoursMinutesSecondsFromTime returns the UTC time of day as hours, minutes and
+// seconds, in the format of GPSTimeStamp. Seconds are kept to the millisecond.
+func RationalHoursMinutesSecondsFromTime(t time.Time) []exifcommon.Rational {
+	t = t.UTC()
+
+	seconds := exifcommon.Rational{Numerator: uint32(t.Second()), Denominator: 1}
+	if ms := t.Nanosecond() / int(time.Millisecond); ms > 0 {
+		seconds = exifcommon.Rational{Numerator: uint32(t.Second()*1000 + ms), Denominator: 1000}
+	}
+
+	return []exifcommon.Rational{
+		{Numerator: uint32(t.Hour()), Denominator: 1},
+		{Numerator: uint32(t.Minute()), Denominator: 1},
+		seconds,
+	}
+}
+
+// DecimalFromRationalDegreesMinutesSeconds converts a degrees, minutes, seconds value to
+// decimal degrees, the result is always positive
+func DecimalFromRationalDegreesMinutesSeconds(dms []exifcommon.Rational) (float64, error) {
+	if len(dms) != 3 {
+		return 0, fmt.Errorf("expected 3 rationals for degrees, minutes and seconds, got %d", len(dms))
+	}
+
+	var parts [3]float64
+	for i, r := range dms {
+		if r.Denominator == 0 {
+			return 0, fmt.Errorf("invalid rational %d/%d", r.Numerator, r.Denominator)
+		}
+		parts[i] = float64(r.Numerator) / float64(r.Denominator)
+	}
+
+	return parts[0] + parts[1]/60 + parts[2]/3600, nil
+}
+
 // getIndexedTagFromName looks up tag index values to use for supplied tags. When we have a new tag that's not in the
 // current file, then we need to look up where it should go in the EXIF tree
 func getIndexedTagFromName(k string) (*exifcommon.IfdIdentity, *exif.IndexedTag, error) {
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"testing"
 	"time"
@@ -37,6 +38,18 @@ func TestSetKey(t *testing.T) {
 			Key:     "GPSLatitude",
 			Value:   RationalDegreesMinutesSecondsFromDecimal(51.56736389),
 		},
+		"set DateTimeOriginal in HEIC": {
+			Image:   "./fixtures/iphone.HEIC",
+			IFDPath: "IFD/Exif",
+			Key:     "DateTimeOriginal",
+			Value:   "2022:08:03 17:57:45",
+		},
+		"set GPSLatitude in HEIC": {
+			Image:   "./fixtures/iphone.HEIC",
+			IFDPath: "IFD/GPSInfo",
+			Key:     "GPSLatitude",
+			Value:   RationalDegreesMinutesSecondsFromDecimal(51.56736389),
+		},
 		"set GPSLatitude when missing in original": {
 			Image:   "./fixtures/x100f.jpg",
 			IFDPath: "IFD/GPSInfo",
@@ -77,6 +90,96 @@ func TestSetKey(t *testing.T) {
 	}
 }
 
+func TestApply(t *testing.T) {
+	testCases := map[string]struct {
+		Image   string
+		Changes []Change
+	}{
+		"set time and location in JPEG": {
+			Image: "./fixtures/iphone.JPG",
+			Changes: []Change{
+				{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 17:56:22"},
+				{IFDPath: "IFD/Exif", Key: "OffsetTimeOriginal", Value: "+00:00"},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSLatitude", Value: RationalDegreesMinutesSecondsFromDecimal(51.56736389)},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSLatitudeRef", Value: "N"},
+			},
+		},
+		"set time and location in HEIC": {
+			Image: "./fixtures/iphone.HEIC",
+			Changes: []Change{
+				{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 17:57:45"},
+				{IFDPath: "IFD/Exif", Key: "OffsetTimeOriginal", Value: "+00:00"},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSLongitude", Value: RationalDegreesMinutesSecondsFromDecimal(-0.13843)},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSLongitudeRef", Value: "W"},
+			},
+		},
+		"set byte, short and undefined values": {
+			Image: "./fixtures/iphone.JPG",
+			Changes: []Change{
+				{IFDPath: "IFD/GPSInfo", Key: "GPSVersionID", Value: []byte{2, 3, 0, 0}},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSAltitudeRef", Value: []byte{1}},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSDifferential", Value: []uint16{1}},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSProcessingMethod", Value: CharacterCodeASCII("GPS")},
+			},
+		},
+		"set byte and undefined values in HEIC": {
+			Image: "./fixtures/iphone.HEIC",
+			Changes: []Change{
+				{IFDPath: "IFD/GPSInfo", Key: "GPSVersionID", Value: []byte{2, 3, 0, 0}},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSProcessingMethod", Value: CharacterCodeASCII("GPS")},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			imageCopy, err := ioutil.TempFile(".", "image_")
+			require.NoError(t, err)
+			defer os.Remove(imageCopy.Name())
+
+			imageFile, err := os.Open(testCase.Image)
+			require.NoError(t, err)
+			defer imageFile.Close()
+
+			_, err = io.Copy(imageCopy, imageFile)
+			require.NoError(t, err)
+
+			err = Apply(imageCopy.Name(), testCase.Changes)
+			require.NoError(t, err)
+
+			for _, c := range testCase.Changes {
+				value, err := GetKey(imageCopy.Name(), c.IFDPath, c.Key)
+				require.NoError(t, err)
+				assert.Equal(t, c.Value, value)
+			}
+		})
+	}
+}
+
+func TestApplyUnsupportedValue(t *testing.T) {
+	imageCopy, err := ioutil.TempFile(".", "image_")
+	require.NoError(t, err)
+	defer os.Remove(imageCopy.Name())
+
+	imageFile, err := os.Open("./fixtures/iphone.JPG")
+	require.NoError(t, err)
+	defer imageFile.Close()
+
+	_, err = io.Copy(imageCopy, imageFile)
+	require.NoError(t, err)
+
+	err = Apply(imageCopy.Name(), []Change{
+		{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2000:01:01 00:00:00"},
+		{IFDPath: "IFD/Exif", Key: "SubSecTimeOriginal", Value: 12.5},
+	})
+	require.Error(t, err)
+
+	// no changes are written when any change fails
+	value, err := GetKey(imageCopy.Name(), "IFD/Exif", "DateTimeOriginal")
+	require.NoError(t, err)
+	assert.Equal(t, "2022:08:03 18:56:22", value)
+}
+
 func TestSetLocalTime(t *testing.T) {
 	location, err := time.LoadLocation("Europe/London")
 	require.NoError(t, err)
@@ -165,6 +268,22 @@ func TestGetKey(t *testing.T) {
 				{251, 100},
 			},
 		},
+		"get DateTimeOriginal from HEIC": {
+			Image:         "./fixtures/iphone.HEIC",
+			IFDPath:       "IFD/Exif",
+			Key:           "DateTimeOriginal",
+			ExpectedValue: "2022:08:03 18:57:45",
+		},
+		"get GPSLatitude from HEIC": {
+			Image:   "./fixtures/iphone.HEIC",
+			IFDPath: "IFD/GPSInfo",
+			Key:     "GPSLatitude",
+			ExpectedValue: []exifcommon.Rational{
+				{Numerator: 51, Denominator: 1},
+				{Numerator: 34, Denominator: 1},
+				{Numerator: 232, Denominator: 100},
+			},
+		},
 	}
 
 	for name, testCase := range testCases {
@@ -255,3 +374,303 @@ func TestGetUTC(t *testing.T) {
 		})
 	}
 }
+
+func TestInverse(t *testing.T) {
+	testCases := map[string]struct {
+		Image    string
+		Changes  []Change
+		Expected []Change
+	}{
+		"existing and missing values": {
+			Image: "./fixtures/iphone.JPG",
+			Changes: []Change{
+				{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 17:56:22"},
+				{IFDPath: "IFD/Exif", Key: "LensModel", Value: "new"},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSLatitude", Value: RationalDegreesMinutesSecondsFromDecimal(1.5)},
+			},
+			Expected: []Change{
+				{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 18:56:22"},
+				{IFDPath: "IFD/Exif", Key: "LensModel", Value: "iPhone 11 Pro Max back triple camera 4.25mm f/1.8"},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSLatitude", Value: []exifcommon.Rational{
+					{Numerator: 51, Denominator: 1},
+					{Numerator: 34, Denominator: 1},
+					{Numerator: 251, Denominator: 100},
+				}},
+			},
+		},
+		"missing value": {
+			Image: "./fixtures/iphone.JPG",
+			Changes: []Change{
+				{IFDPath: "IFD/Exif", Key: "ImageUniqueID", Value: "new"},
+			},
+			Expected: []Change{
+				{IFDPath: "IFD/Exif", Key: "ImageUniqueID", Value: nil},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			inverse, err := Inverse(testCase.Image, testCase.Changes)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Expected, inverse)
+		})
+	}
+}
+
+func TestRationalHoursMinutesSecondsFromTime(t *testing.T) {
+	testCases := map[string]struct {
+		Time     time.Time
+		Expected []exifcommon.Rational
+	}{
+		"whole seconds": {
+			Time: time.Date(2022, 8, 3, 23, 18, 7, 0, time.UTC),
+			Expected: []exifcommon.Rational{
+				{Numerator: 23, Denominator: 1},
+				{Numerator: 18, Denominator: 1},
+				{Numerator: 7, Denominator: 1},
+			},
+		},
+		"milliseconds": {
+			Time: time.Date(2022, 8, 3, 17, 56, 22, 480000000, time.UTC),
+			Expected: []exifcommon.Rational{
+				{Numerator: 17, Denominator: 1},
+				{Numerator: 56, Denominator: 1},
+				{Numerator: 22480, Denominator: 1000},
+			},
+		},
+		"converted to UTC": {
+			Time: time.Date(2022, 8, 3, 18, 56, 22, 0, time.FixedZone("BST", 3600)),
+			Expected: []exifcommon.Rational{
+				{Numerator: 17, Denominator: 1},
+				{Numerator: 56, Denominator: 1},
+				{Numerator: 22, Denominator: 1},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testCase.Expected, RationalHoursMinutesSecondsFromTime(testCase.Time))
+		})
+	}
+}
+
+func TestGetGPS(t *testing.T) {
+	testCases := map[string]struct {
+		Image    string
+		Expected *GPS
+	}{
+		"jpg": {
+			Image: "./fixtures/iphone.JPG",
+			Expected: &GPS{
+				Latitude:    51.567364,
+				Longitude:   -0.138711,
+				Altitude:    floatPointer(74.548),
+				AltitudeRef: bytePointer(0),
+			},
+		},
+		"heic": {
+			Image: "./fixtures/iphone.HEIC",
+			Expected: &GPS{
+				Latitude:    51.567311,
+				Longitude:   -0.138561,
+				Altitude:    floatPointer(74.506),
+				AltitudeRef: bytePointer(0),
+			},
+		},
+		"no location": {
+			Image:    "./fixtures/iphone_other_tz.JPG",
+			Expected: nil,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g, err := GetGPS(testCase.Image)
+			require.NoError(t, err)
+
+			if testCase.Expected == nil {
+				assert.Nil(t, g)
+				return
+			}
+			require.NotNil(t, g)
+
+			assert.InDelta(t, testCase.Expected.Latitude, g.Latitude, 0.000001)
+			assert.InDelta(t, testCase.Expected.Longitude, g.Longitude, 0.000001)
+			require.NotNil(t, g.Altitude)
+			assert.InDelta(t, *testCase.Expected.Altitude, *g.Altitude, 0.001)
+			assert.Equal(t, testCase.Expected.AltitudeRef, g.AltitudeRef)
+			assert.Equal(t, testCase.Expected.Timestamp, g.Timestamp)
+			assert.Equal(t, testCase.Expected.Datum, g.Datum)
+			assert.NoError(t, g.Validate())
+		})
+	}
+}
+
+func TestGPSFromValues(t *testing.T) {
+	position := func() map[string]interface{} {
+		return map[string]interface{}{
+			"GPSLatitude":     []exifcommon.Rational{{Numerator: 51, Denominator: 1}, {Numerator: 30, Denominator: 1}, {Numerator: 0, Denominator: 1}},
+			"GPSLatitudeRef":  "N",
+			"GPSLongitude":    []exifcommon.Rational{{Numerator: 0, Denominator: 1}, {Numerator: 6, Denominator: 1}, {Numerator: 0, Denominator: 1}},
+			"GPSLongitudeRef": "W",
+		}
+	}
+
+	testCases := map[string]struct {
+		Values           map[string]interface{}
+		ExpectedError    string
+		ExpectedAltitude *float64
+		ExpectTimestamp  bool
+	}{
+		"valid with optional fields": {
+			Values: map[string]interface{}{
+				"GPSAltitude":    []exifcommon.Rational{{Numerator: 10, Denominator: 1}},
+				"GPSAltitudeRef": []byte{1},
+				"GPSDateStamp":   "2022:08:03",
+				"GPSTimeStamp":   []exifcommon.Rational{{Numerator: 9, Denominator: 1}, {Numerator: 0, Denominator: 1}, {Numerator: 0, Denominator: 1}},
+			},
+			ExpectedAltitude: floatPointer(-10),
+			ExpectTimestamp:  true,
+		},
+		"zero denominator in latitude": {
+			Values: map[string]interface{}{
+				"GPSLatitude": []exifcommon.Rational{{Numerator: 51, Denominator: 0}, {Numerator: 30, Denominator: 1}, {Numerator: 0, Denominator: 1}},
+			},
+			ExpectedError: "invalid GPSLatitude: invalid rational 51/0",
+		},
+		"unknown latitude ref": {
+			Values: map[string]interface{}{
+				"GPSLatitudeRef": "X",
+			},
+			ExpectedError: `invalid GPSLatitudeRef: "X"`,
+		},
+		"undecodable optional fields are ignored": {
+			Values: map[string]interface{}{
+				"GPSAltitude":    []exifcommon.Rational{{Numerator: 10, Denominator: 0}},
+				"GPSAltitudeRef": []byte{1, 2},
+				"GPSDateStamp":   "yesterday",
+				"GPSTimeStamp":   []exifcommon.Rational{{Numerator: 9, Denominator: 1}},
+				"GPSMapDatum":    []byte("WGS-84"),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			values := position()
+			for k, v := range testCase.Values {
+				values[k] = v
+			}
+
+			g := gpsFromValues(values)
+			require.NotNil(t, g)
+
+			if testCase.ExpectedError != "" {
+				assert.EqualError(t, g.Validate(), testCase.ExpectedError)
+				return
+			}
+			require.NoError(t, g.Validate())
+
+			assert.InDelta(t, 51.5, g.Latitude, 0.000001)
+			assert.InDelta(t, -0.1, g.Longitude, 0.000001)
+			assert.Equal(t, testCase.ExpectedAltitude, g.Altitude)
+			assert.Equal(t, testCase.ExpectTimestamp, g.Timestamp != nil)
+			assert.Empty(t, g.Datum)
+		})
+	}
+}
+
+func TestGPSValidate(t *testing.T) {
+	testCases := map[string]struct {
+		GPS           GPS
+		ExpectedError string
+	}{
+		"valid": {
+			GPS: GPS{Latitude: 51.5, Longitude: -0.1},
+		},
+		"null island": {
+			GPS:           GPS{Latitude: 0, Longitude: 0},
+			ExpectedError: "position is 0,0",
+		},
+		"on the equator": {
+			GPS: GPS{Latitude: 0, Longitude: 32.5},
+		},
+		"latitude out of range": {
+			GPS:           GPS{Latitude: 91, Longitude: 0.1},
+			ExpectedError: "latitude 91.000000 is out of range",
+		},
+		"longitude out of range": {
+			GPS:           GPS{Latitude: 10, Longitude: -181},
+			ExpectedError: "longitude -181.000000 is out of range",
+		},
+		"not a number": {
+			GPS:           GPS{Latitude: math.NaN(), Longitude: 1},
+			ExpectedError: "position is not a number",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.GPS.Validate()
+			if testCase.ExpectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, testCase.ExpectedError)
+		})
+	}
+}
+
+func TestTimestampFromValues(t *testing.T) {
+	testCases := map[string]struct {
+		DateStamp     interface{}
+		TimeStamp     interface{}
+		Expected      time.Time
+		ExpectedError bool
+	}{
+		"whole seconds": {
+			DateStamp: "2022:08:03",
+			TimeStamp: []exifcommon.Rational{{Numerator: 16, Denominator: 1}, {Numerator: 53, Denominator: 1}, {Numerator: 12, Denominator: 1}},
+			Expected:  time.Date(2022, 8, 3, 16, 53, 12, 0, time.UTC),
+		},
+		"fractional seconds": {
+			DateStamp: "2022:08:03",
+			TimeStamp: []exifcommon.Rational{{Numerator: 16, Denominator: 1}, {Numerator: 53, Denominator: 1}, {Numerator: 1225, Denominator: 100}},
+			Expected:  time.Date(2022, 8, 3, 16, 53, 12, 250000000, time.UTC),
+		},
+		"invalid date": {
+			DateStamp:     "2022-08-03",
+			TimeStamp:     []exifcommon.Rational{{Numerator: 16, Denominator: 1}, {Numerator: 53, Denominator: 1}, {Numerator: 12, Denominator: 1}},
+			ExpectedError: true,
+		},
+		"zero denominator": {
+			DateStamp:     "2022:08:03",
+			TimeStamp:     []exifcommon.Rational{{Numerator: 16, Denominator: 1}, {Numerator: 53, Denominator: 0}, {Numerator: 12, Denominator: 1}},
+			ExpectedError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			timestamp, err := timestampFromValues(testCase.DateStamp, testCase.TimeStamp)
+			if testCase.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Expected, timestamp)
+		})
+	}
+}
+
+func floatPointer(f float64) *float64 {
+	return &f
+}
+
+func bytePointer(b byte) *byte {
+	return &b
+}
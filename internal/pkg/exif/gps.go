@@ -0,0 +1,192 @@
+package exif
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// GPS is a position read from an image's GPS tags
+type GPS struct {
+	// Latitude and Longitude are in decimal degrees, negative for south and west
+	Latitude  float64
+	Longitude float64
+	// Altitude is in meters, negative when below sea level. It's nil when not set.
+	Altitude *float64
+	// AltitudeRef is the GPSAltitudeRef value, 0 above sea level and 1 below. It's nil
+	// when not set.
+	AltitudeRef *byte
+	// Timestamp is the UTC time from GPSDateStamp and GPSTimeStamp, it's nil unless
+	// both are set
+	Timestamp *time.Time
+	// Datum is the GPSMapDatum, it's empty when not set and WGS-84 should be assumed
+	Datum string
+
+	// invalid is set when the position tags couldn't be decoded
+	invalid error
+}
+
+// gpsKeys are the tags read by GetGPS
+var gpsKeys = []string{
+	"GPSLatitude",
+	"GPSLatitudeRef",
+	"GPSLongitude",
+	"GPSLongitudeRef",
+	"GPSAltitude",
+	"GPSAltitudeRef",
+	"GPSDateStamp",
+	"GPSTimeStamp",
+	"GPSMapDatum",
+}
+
+// GetGPS returns the image's GPS position, or nil when the image has no position.
+// GPS tags which can't be decoded aren't an error: when the position can't be decoded
+// Validate returns why, and an altitude, time or datum which can't be decoded is left
+// unset. Use Validate to check that the position is plausible.
+func GetGPS(image string) (*GPS, error) {
+	values, err := getKeys(image, "IFD/GPSInfo", gpsKeys...)
+	if errors.Is(err, exif.ErrNoExif) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return gpsFromValues(values), nil
+}
+
+// gpsFromValues returns the GPS data from the values of gpsKeys, or nil when there's no
+// position
+func gpsFromValues(values map[string]interface{}) *GPS {
+	latitudeDMS, hasLatitude := values["GPSLatitude"]
+	longitudeDMS, hasLongitude := values["GPSLongitude"]
+	if !hasLatitude || !hasLongitude {
+		return nil
+	}
+
+	var g GPS
+	g.invalid = g.setPosition(values, latitudeDMS, longitudeDMS)
+
+	if value, ok := values["GPSAltitudeRef"]; ok {
+		if ref, ok := value.([]byte); ok && len(ref) == 1 {
+			g.AltitudeRef = &ref[0]
+		}
+	}
+
+	if value, ok := values["GPSAltitude"]; ok {
+		rationals, ok := value.([]exifcommon.Rational)
+		if ok && len(rationals) == 1 && rationals[0].Denominator != 0 {
+			altitude := float64(rationals[0].Numerator) / float64(rationals[0].Denominator)
+			if g.AltitudeRef != nil && *g.AltitudeRef == 1 {
+				altitude = -altitude
+			}
+			g.Altitude = &altitude
+		}
+	}
+
+	dateStamp, hasDate := values["GPSDateStamp"]
+	timeStamp, hasTime := values["GPSTimeStamp"]
+	if hasDate && hasTime {
+		t, err := timestampFromValues(dateStamp, timeStamp)
+		if err == nil {
+			g.Timestamp = &t
+		}
+	}
+
+	if value, ok := values["GPSMapDatum"]; ok {
+		g.Datum, _ = value.(string)
+	}
+
+	return &g
+}
+
+// setPosition sets the latitude and longitude from the tag values, returning an error
+// if they can't be decoded
+func (g *GPS) setPosition(values map[string]interface{}, latitudeDMS, longitudeDMS interface{}) error {
+	var err error
+
+	g.Latitude, err = decimalFromValue(latitudeDMS)
+	if err != nil {
+		return fmt.Errorf("invalid GPSLatitude: %w", err)
+	}
+	g.Longitude, err = decimalFromValue(longitudeDMS)
+	if err != nil {
+		return fmt.Errorf("invalid GPSLongitude: %w", err)
+	}
+
+	switch ref := values["GPSLatitudeRef"]; ref {
+	case "S":
+		g.Latitude = -g.Latitude
+	case "N", nil:
+	default:
+		return fmt.Errorf("invalid GPSLatitudeRef: %#v", ref)
+	}
+	switch ref := values["GPSLongitudeRef"]; ref {
+	case "W":
+		g.Longitude = -g.Longitude
+	case "E", nil:
+	default:
+		return fmt.Errorf("invalid GPSLongitudeRef: %#v", ref)
+	}
+
+	return nil
+}
+
+// Validate returns an error if the position couldn't be decoded or is obviously wrong,
+// such as being out of range or at 0,0 which is written by some devices without a fix
+func (g *GPS) Validate() error {
+	switch {
+	case g.invalid != nil:
+		return g.invalid
+	case math.IsNaN(g.Latitude) || math.IsNaN(g.Longitude):
+		return fmt.Errorf("position is not a number")
+	case g.Latitude < -90 || g.Latitude > 90:
+		return fmt.Errorf("latitude %f is out of range", g.Latitude)
+	case g.Longitude < -180 || g.Longitude > 180:
+		return fmt.Errorf("longitude %f is out of range", g.Longitude)
+	case g.Latitude == 0 && g.Longitude == 0:
+		return fmt.Errorf("position is 0,0")
+	}
+
+	return nil
+}
+
+func decimalFromValue(value interface{}) (float64, error) {
+	rationals, ok := value.([]exifcommon.Rational)
+	if !ok {
+		return 0, fmt.Errorf("expected rationals, got %#v", value)
+	}
+
+	return DecimalFromRationalDegreesMinutesSeconds(rationals)
+}
+
+// timestampFromValues returns the time from GPSDateStamp and GPSTimeStamp values
+func timestampFromValues(dateStamp, timeStamp interface{}) (time.Time, error) {
+	date, ok := dateStamp.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid GPSDateStamp: %#v", dateStamp)
+	}
+	day, err := time.Parse("2006:01:02", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid GPSDateStamp: %w", err)
+	}
+
+	hms, ok := timeStamp.([]exifcommon.Rational)
+	if !ok || len(hms) != 3 {
+		return time.Time{}, fmt.Errorf("invalid GPSTimeStamp: %#v", timeStamp)
+	}
+
+	var seconds float64
+	for _, r := range hms {
+		if r.Denominator == 0 {
+			return time.Time{}, fmt.Errorf("invalid GPSTimeStamp: %#v", timeStamp)
+		}
+		seconds = seconds*60 + float64(r.Numerator)/float64(r.Denominator)
+	}
+
+	return day.Add(time.Duration(seconds * float64(time.Second))).Round(time.Millisecond), nil
+}
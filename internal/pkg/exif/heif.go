@@ -0,0 +1,474 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// exifItemPrefix is placed between the tiff header offset and the tiff header of
+// the Exif items written by this package
+var exifItemPrefix = []byte("Exif\x00\x00")
+
+// heifBrands are the ftyp major brands for HEIF images (ISO/IEC 23008-12)
+var heifBrands = map[string]bool{
+	"heic": true,
+	"heix": true,
+	"heim": true,
+	"heis": true,
+	"hevc": true,
+	"hevx": true,
+	"mif1": true,
+	"msf1": true,
+	"avif": true,
+}
+
+// isHEIF returns true if the data starts with an ISOBMFF ftyp box for a HEIF brand
+func isHEIF(data []byte) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+
+	return heifBrands[string(data[8:12])]
+}
+
+// heifImage holds a HEIF image, such as a HEIC from an iPhone, and the location
+// of its Exif item. It's able to replace the Exif item's data either in place,
+// when the new data fits, or by appending the data in a new mdat box and
+// updating the item's iloc entry.
+type heifImage struct {
+	data []byte
+
+	// exifOffset and exifLength locate the Exif item's payload in data
+	exifOffset, exifLength uint64
+
+	// baseOffset is the iloc base offset of the Exif item
+	baseOffset uint64
+	// extentOffsetPos and extentLengthPos are the positions in data of the iloc
+	// fields describing the Exif item's extent
+	extentOffsetPos, extentLengthPos int
+	// offsetSize and lengthSize are the sizes in bytes of the iloc extent fields
+	offsetSize, lengthSize int
+
+	// openEndedBox is the position of the last top-level box when its size is 0, meaning
+	// it extends to the end of the file, or -1
+	openEndedBox int
+
+	// payload is the Exif item data to write, set by SetExif
+	payload []byte
+}
+
+// box is an ISOBMFF box located within a byte slice
+type box struct {
+	boxType string
+	// start is the position of the box header, body is the position of the box contents
+	start, body, end int
+}
+
+// readBoxes returns the boxes found in data between start and end
+func readBoxes(data []byte, start, end int) ([]box, error) {
+	var boxes []box
+
+	for offset := start; offset < end; {
+		if end-offset < 8 {
+			return nil, fmt.Errorf("truncated box header at %d", offset)
+		}
+
+		size := uint64(binary.BigEndian.Uint32(data[offset:]))
+		boxType := string(data[offset+4 : offset+8])
+		body := offset + 8
+
+		switch size {
+		case 0:
+			size = uint64(end - offset)
+		case 1:
+			if end-offset < 16 {
+				return nil, fmt.Errorf("truncated large box header at %d", offset)
+			}
+			size = binary.BigEndian.Uint64(data[offset+8:])
+			body = offset + 16
+		}
+
+		if size < uint64(body-offset) || size > uint64(end-offset) {
+			return nil, fmt.Errorf("invalid size %d for box %q at %d", size, boxType, offset)
+		}
+
+		boxes = append(boxes, box{boxType: boxType, start: offset, body: body, end: offset + int(size)})
+		offset += int(size)
+	}
+
+	return boxes, nil
+}
+
+func findBox(boxes []box, boxType string) (box, bool) {
+	for _, b := range boxes {
+		if b.boxType == boxType {
+			return b, true
+		}
+	}
+
+	return box{}, false
+}
+
+// parseHEIF locates the Exif item in a HEIF image using the meta box's iinf and iloc boxes
+func parseHEIF(data []byte) (*heifImage, error) {
+	topLevel, err := readBoxes(data, 0, len(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read boxes: %w", err)
+	}
+
+	meta, ok := findBox(topLevel, "meta")
+	if !ok {
+		return nil, fmt.Errorf("meta box not found")
+	}
+
+	// meta is a full box, skip the version and flags
+	metaBoxes, err := readBoxes(data, meta.body+4, meta.end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meta boxes: %w", err)
+	}
+
+	iinf, ok := findBox(metaBoxes, "iinf")
+	if !ok {
+		return nil, fmt.Errorf("iinf box not found")
+	}
+	exifItemID, err := findExifItemID(data, iinf)
+	if err != nil {
+		return nil, err
+	}
+
+	iloc, ok := findBox(metaBoxes, "iloc")
+	if !ok {
+		return nil, fmt.Errorf("iloc box not found")
+	}
+	h, err := locateItem(data, iloc, exifItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the checks are ordered so that large values can't overflow
+	if h.exifOffset > uint64(len(data)) || h.exifLength > uint64(len(data))-h.exifOffset {
+		return nil, fmt.Errorf("exif item extent is outside of the file")
+	}
+	if h.exifLength < 4 {
+		return nil, fmt.Errorf("exif item is too short")
+	}
+
+	h.openEndedBox = -1
+	if last := topLevel[len(topLevel)-1]; binary.BigEndian.Uint32(data[last.start:]) == 0 {
+		h.openEndedBox = last.start
+	}
+
+	return h, nil
+}
+
+// findExifItemID returns the item ID of the first item with the type Exif
+func findExifItemID(data []byte, iinf box) (uint32, error) {
+	r := &boxReader{data: data, pos: iinf.body, end: iinf.end}
+
+	version := r.uint(1)
+	r.skip(3)
+
+	entryCountSize := 2
+	if version > 0 {
+		entryCountSize = 4
+	}
+	r.uint(entryCountSize)
+	if r.err != nil {
+		return 0, fmt.Errorf("failed to read iinf header: %w", r.err)
+	}
+
+	entries, err := readBoxes(data, r.pos, iinf.end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read iinf entries: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.boxType != "infe" {
+			continue
+		}
+
+		r := &boxReader{data: data, pos: entry.body, end: entry.end}
+		version := r.uint(1)
+		r.skip(3)
+
+		// item types were only added in version 2 of the infe box
+		if version < 2 {
+			continue
+		}
+
+		idSize := 2
+		if version > 2 {
+			idSize = 4
+		}
+		itemID := r.uint(idSize)
+		r.skip(2) // item_protection_index
+		itemType := r.bytes(4)
+		if r.err != nil {
+			return 0, fmt.Errorf("failed to read infe box: %w", r.err)
+		}
+
+		if string(itemType) == "Exif" {
+			return uint32(itemID), nil
+		}
+	}
+
+	return 0, exif.ErrNoExif
+}
+
+// locateItem reads the iloc box to find the location of the given item
+func locateItem(data []byte, iloc box, itemID uint32) (*heifImage, error) {
+	r := &boxReader{data: data, pos: iloc.body, end: iloc.end}
+
+	version := r.uint(1)
+	r.skip(3)
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0f)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0f)
+	if version == 0 {
+		indexSize = 0
+	}
+	for _, size := range []int{offsetSize, lengthSize, baseOffsetSize, indexSize} {
+		if size != 0 && size != 4 && size != 8 {
+			return nil, fmt.Errorf("invalid iloc field size %d", size)
+		}
+	}
+
+	countSize := 2
+	if version == 2 {
+		countSize = 4
+	}
+	itemCount := r.uint(countSize)
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to read iloc header: %w", r.err)
+	}
+
+	for i := uint64(0); i < itemCount; i++ {
+		id := r.uint(countSize)
+
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			constructionMethod = r.uint(2) & 0x0f
+		}
+		r.skip(2) // data_reference_index
+		baseOffset := r.uint(baseOffsetSize)
+		extentCount := r.uint(2)
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to read iloc item: %w", r.err)
+		}
+
+		h := &heifImage{
+			data:       data,
+			baseOffset: baseOffset,
+			offsetSize: offsetSize,
+			lengthSize: lengthSize,
+		}
+
+		for e := uint64(0); e < extentCount; e++ {
+			r.skip(indexSize)
+			h.extentOffsetPos = r.pos
+			h.exifOffset = baseOffset + r.uint(offsetSize)
+			h.extentLengthPos = r.pos
+			h.exifLength = r.uint(lengthSize)
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to read iloc extents: %w", r.err)
+		}
+
+		if uint32(id) != itemID {
+			continue
+		}
+
+		if constructionMethod != 0 {
+			return nil, fmt.Errorf("unsupported iloc construction method %d for exif item", constructionMethod)
+		}
+		if extentCount != 1 {
+			return nil, fmt.Errorf("unsupported extent count %d for exif item", extentCount)
+		}
+		if h.exifOffset < baseOffset {
+			return nil, fmt.Errorf("exif item offset overflows")
+		}
+		if lengthSize == 0 {
+			// the extent runs to the end of the file
+			if h.exifOffset > uint64(len(data)) {
+				return nil, fmt.Errorf("exif item offset %d is after end of file", h.exifOffset)
+			}
+			h.exifLength = uint64(len(data)) - h.exifOffset
+		}
+
+		return h, nil
+	}
+
+	return nil, fmt.Errorf("exif item %d not found in iloc box", itemID)
+}
+
+// tiffData returns the Exif item's data from the tiff header onwards
+func (h *heifImage) tiffData() ([]byte, error) {
+	item := h.data[h.exifOffset : h.exifOffset+h.exifLength]
+
+	tiffHeaderOffset := uint64(binary.BigEndian.Uint32(item))
+	if 4+tiffHeaderOffset > uint64(len(item)) {
+		return nil, fmt.Errorf("invalid exif tiff header offset %d", tiffHeaderOffset)
+	}
+
+	return item[4+tiffHeaderOffset:], nil
+}
+
+// Exif returns the root IFD and raw data of the image's EXIF data
+func (h *heifImage) Exif() (*exif.Ifd, []byte, error) {
+	rawExif, err := h.tiffData()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create idfmapping: %w", err)
+	}
+
+	_, index, err := exif.Collect(im, exif.NewTagIndex(), rawExif)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to collect exif data: %w", err)
+	}
+
+	return index.RootIfd, rawExif, nil
+}
+
+// ConstructExifBuilder returns a builder preloaded with the image's existing tags
+func (h *heifImage) ConstructExifBuilder() (*exif.IfdBuilder, error) {
+	rootIfd, _, err := h.Exif()
+	if err != nil {
+		return nil, err
+	}
+
+	return exif.NewIfdBuilderFromExistingChain(rootIfd), nil
+}
+
+// SetExif encodes the builder's tags as the new Exif item data
+func (h *heifImage) SetExif(ib *exif.IfdBuilder) error {
+	exifData, err := exif.NewIfdByteEncoder().EncodeToExif(ib)
+	if err != nil {
+		return fmt.Errorf("failed to encode exif data: %w", err)
+	}
+
+	payload := make([]byte, 4, 4+len(exifItemPrefix)+len(exifData))
+	binary.BigEndian.PutUint32(payload, uint32(len(exifItemPrefix)))
+	payload = append(payload, exifItemPrefix...)
+	payload = append(payload, exifData...)
+
+	h.payload = payload
+
+	return nil
+}
+
+// Write writes the image with the new Exif item data, if any, to w
+func (h *heifImage) Write(w io.Writer) error {
+	if h.payload == nil {
+		_, err := w.Write(h.data)
+		return err
+	}
+
+	out := make([]byte, len(h.data), len(h.data)+len(h.payload)+16)
+	copy(out, h.data)
+
+	payloadLength := uint64(len(h.payload))
+
+	if payloadLength <= h.exifLength {
+		// the new data fits in the existing extent, the remainder is zeroed
+		extent := out[h.exifOffset : h.exifOffset+h.exifLength]
+		copy(extent, h.payload)
+		for i := payloadLength; i < h.exifLength; i++ {
+			extent[i] = 0
+		}
+	} else {
+		// relocate the item to a new mdat box at the end of the file
+		var mdat bytes.Buffer
+		header := make([]byte, 8)
+		binary.BigEndian.PutUint32(header, uint32(8+payloadLength))
+		copy(header[4:], "mdat")
+		mdat.Write(header)
+		mdat.Write(h.payload)
+
+		newOffset := uint64(len(out)) + 8
+		if newOffset < h.baseOffset {
+			return fmt.Errorf("exif item base offset %d is after end of file", h.baseOffset)
+		}
+		if err := putUint(out[h.extentOffsetPos:], h.offsetSize, newOffset-h.baseOffset); err != nil {
+			return fmt.Errorf("failed to update exif item offset: %w", err)
+		}
+
+		// a last box extending to the end of the file would contain the new box, so
+		// its size is set
+		if h.openEndedBox >= 0 {
+			if err := putUint(out[h.openEndedBox:], 4, uint64(len(out)-h.openEndedBox)); err != nil {
+				return fmt.Errorf("failed to set size of last box: %w", err)
+			}
+		}
+
+		out = append(out, mdat.Bytes()...)
+	}
+
+	if h.lengthSize > 0 {
+		if err := putUint(out[h.extentLengthPos:], h.lengthSize, payloadLength); err != nil {
+			return fmt.Errorf("failed to update exif item length: %w", err)
+		}
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+// putUint writes value to b as a big endian unsigned integer of size bytes
+func putUint(b []byte, size int, value uint64) error {
+	if size == 0 || (size < 8 && value >= 1<<(8*uint(size))) {
+		return fmt.Errorf("value %d does not fit in %d bytes", value, size)
+	}
+
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(value)
+		value >>= 8
+	}
+
+	return nil
+}
+
+// boxReader reads big endian values from box contents, the first error
+// encountered is kept and subsequent reads return zero values
+type boxReader struct {
+	data     []byte
+	pos, end int
+	err      error
+}
+
+func (r *boxReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > r.end {
+		r.err = fmt.Errorf("unexpected end of box at %d", r.pos)
+		return nil
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+
+	return b
+}
+
+func (r *boxReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *boxReader) uint(n int) uint64 {
+	var value uint64
+	for _, b := range r.bytes(n) {
+		value = value<<8 | uint64(b)
+	}
+
+	return value
+}
@@ -0,0 +1,188 @@
+package exif
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHEIF(t *testing.T) {
+	testCases := map[string]struct {
+		Image    string
+		Expected bool
+	}{
+		"heic": {
+			Image:    "./fixtures/iphone.HEIC",
+			Expected: true,
+		},
+		"jpeg": {
+			Image:    "./fixtures/iphone.JPG",
+			Expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b, err := ioutil.ReadFile(testCase.Image)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Expected, isHEIF(b))
+		})
+	}
+}
+
+func TestSetKeyHEIFRelocation(t *testing.T) {
+	testCases := map[string]struct {
+		LensModel      string
+		ExpectRelocate bool
+		// OpenEndedMdat sets the size of the image's last box, its mdat, to 0 so that it
+		// extends to the end of the file
+		OpenEndedMdat bool
+	}{
+		"when new exif data fits in place": {
+			LensModel:      "short",
+			ExpectRelocate: false,
+		},
+		"when new exif data must be relocated": {
+			LensModel:      strings.Repeat("x", 20000),
+			ExpectRelocate: true,
+		},
+		"when new exif data must be relocated after a box extending to the end of the file": {
+			LensModel:      strings.Repeat("x", 20000),
+			ExpectRelocate: true,
+			OpenEndedMdat:  true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			imageCopy, err := ioutil.TempFile(".", "image_")
+			require.NoError(t, err)
+			defer os.Remove(imageCopy.Name())
+
+			imageFile, err := os.Open("./fixtures/iphone.HEIC")
+			require.NoError(t, err)
+			defer imageFile.Close()
+
+			_, err = io.Copy(imageCopy, imageFile)
+			require.NoError(t, err)
+
+			if testCase.OpenEndedMdat {
+				b, err := ioutil.ReadFile(imageCopy.Name())
+				require.NoError(t, err)
+				boxes, err := readBoxes(b, 0, len(b))
+				require.NoError(t, err)
+
+				_, err = imageCopy.WriteAt([]byte{0, 0, 0, 0}, int64(boxes[len(boxes)-1].start))
+				require.NoError(t, err)
+			}
+
+			originalInfo, err := imageCopy.Stat()
+			require.NoError(t, err)
+
+			err = SetKey(imageCopy.Name(), "IFD/Exif", "LensModel", testCase.LensModel)
+			require.NoError(t, err)
+
+			value, err := GetKey(imageCopy.Name(), "IFD/Exif", "LensModel")
+			require.NoError(t, err)
+			assert.Equal(t, testCase.LensModel, value)
+
+			// other values must be retained
+			utcTime, err := GetUTC(imageCopy.Name())
+			require.NoError(t, err)
+			assert.Equal(t, time.Date(2022, time.August, 3, 17, 57, 45, 986000000, time.UTC), utcTime)
+
+			b, err := ioutil.ReadFile(imageCopy.Name())
+			require.NoError(t, err)
+
+			boxes, err := readBoxes(b, 0, len(b))
+			require.NoError(t, err)
+			assert.Equal(t, "mdat", boxes[len(boxes)-1].boxType)
+
+			if testCase.ExpectRelocate {
+				assert.Greater(t, int64(len(b)), originalInfo.Size())
+				assert.Len(t, boxes, 4)
+			} else {
+				assert.Equal(t, originalInfo.Size(), int64(len(b)))
+				assert.Len(t, boxes, 3)
+			}
+		})
+	}
+}
+
+func TestParseHEIFErrors(t *testing.T) {
+	testCases := map[string]struct {
+		// Sizes are the iloc offset_size and length_size
+		Sizes         byte
+		Offset        uint64
+		Length        uint64
+		ExpectedError string
+	}{
+		"invalid field size": {
+			Sizes:         0x33,
+			ExpectedError: "invalid iloc field size 3",
+		},
+		"extent to the end of the file starting after it": {
+			Sizes:         0x40,
+			Offset:        1 << 20,
+			ExpectedError: "exif item offset 1048576 is after end of file",
+		},
+		"extent longer than the file": {
+			Sizes:         0x44,
+			Offset:        8,
+			Length:        1 << 31,
+			ExpectedError: "exif item extent is outside of the file",
+		},
+		"extent which overflows": {
+			Sizes:         0x88,
+			Offset:        1<<64 - 16,
+			Length:        32,
+			ExpectedError: "exif item extent is outside of the file",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseHEIF(testHEIF(testCase.Sizes, testCase.Offset, testCase.Length))
+			assert.EqualError(t, err, testCase.ExpectedError)
+		})
+	}
+}
+
+// testHEIF returns a HEIF file with an Exif item at the given iloc extent, sizes holds
+// the iloc offset_size and length_size
+func testHEIF(sizes byte, offset, length uint64) []byte {
+	newBox := func(boxType string, body ...[]byte) []byte {
+		b := append(make([]byte, 4), boxType...)
+		for _, part := range body {
+			b = append(b, part...)
+		}
+		binary.BigEndian.PutUint32(b, uint32(len(b)))
+		return b
+	}
+	uintOfSize := func(size byte, value uint64) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, value)
+		return b[8-size:]
+	}
+
+	infe := newBox("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif\x00"))
+	iinf := newBox("iinf", []byte{0, 0, 0, 0, 0, 1}, infe)
+	iloc := newBox("iloc",
+		[]byte{0, 0, 0, 0, sizes, 0, 0, 1, 0, 1, 0, 0, 0, 1},
+		uintOfSize(sizes>>4, offset),
+		uintOfSize(sizes&0x0f, length),
+	)
+
+	return append(
+		newBox("ftyp", []byte("heic\x00\x00\x00\x00")),
+		newBox("meta", []byte{0, 0, 0, 0}, iinf, iloc)...,
+	)
+}
@@ -0,0 +1,243 @@
+package exif
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"gopkg.in/yaml.v3"
+)
+
+// Value wraps a tag value so that it can be serialized along with its type and
+// restored to the same Go type, e.g. for storing changes in a file
+type Value struct {
+	V any
+}
+
+// encodedValue is the serialized form of a Value, bytes and shorts are encoded as lists
+// of numbers, rationals as "numerator/denominator" strings and undefined values as
+// base64 strings
+type encodedValue struct {
+	Type  string `json:"type" yaml:"type"`
+	Value any    `json:"value" yaml:"value"`
+}
+
+func (v Value) encode() (*encodedValue, error) {
+	switch value := v.V.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return &encodedValue{Type: "ascii", Value: value}, nil
+	case []byte:
+		numbers := make([]uint, len(value))
+		for i, b := range value {
+			numbers[i] = uint(b)
+		}
+		return &encodedValue{Type: "byte", Value: numbers}, nil
+	case []uint16:
+		numbers := make([]uint, len(value))
+		for i, n := range value {
+			numbers[i] = uint(n)
+		}
+		return &encodedValue{Type: "short", Value: numbers}, nil
+	case Undefined:
+		return &encodedValue{Type: "undefined", Value: base64.StdEncoding.EncodeToString(value)}, nil
+	case []exifcommon.Rational:
+		rationals := make([]string, len(value))
+		for i, r := range value {
+			rationals[i] = fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
+		}
+		return &encodedValue{Type: "rational", Value: rationals}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value type: %s", reflect.TypeOf(v.V))
+}
+
+func (v *Value) decode(e *encodedValue) error {
+	if e == nil {
+		v.V = nil
+		return nil
+	}
+
+	switch e.Type {
+	case "ascii":
+		s, ok := e.Value.(string)
+		if !ok {
+			return fmt.Errorf("ascii value was not a string: %#v", e.Value)
+		}
+		v.V = s
+		return nil
+	case "byte":
+		numbers, err := decodeNumbers(e.Value, math.MaxUint8)
+		if err != nil {
+			return fmt.Errorf("invalid byte value: %w", err)
+		}
+		bytes := make([]byte, len(numbers))
+		for i, n := range numbers {
+			bytes[i] = byte(n)
+		}
+		v.V = bytes
+		return nil
+	case "short":
+		numbers, err := decodeNumbers(e.Value, math.MaxUint16)
+		if err != nil {
+			return fmt.Errorf("invalid short value: %w", err)
+		}
+		shorts := make([]uint16, len(numbers))
+		for i, n := range numbers {
+			shorts[i] = uint16(n)
+		}
+		v.V = shorts
+		return nil
+	case "undefined":
+		s, ok := e.Value.(string)
+		if !ok {
+			return fmt.Errorf("undefined value was not a string: %#v", e.Value)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("failed to decode undefined value: %w", err)
+		}
+		v.V = Undefined(b)
+		return nil
+	case "rational":
+		items, ok := e.Value.([]any)
+		if !ok {
+			return fmt.Errorf("rational value was not a list: %#v", e.Value)
+		}
+
+		rationals := make([]exifcommon.Rational, len(items))
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("rational was not a string: %#v", item)
+			}
+
+			r, err := parseRational(s)
+			if err != nil {
+				return err
+			}
+			rationals[i] = r
+		}
+		v.V = rationals
+		return nil
+	}
+
+	return fmt.Errorf("unsupported value type %q", e.Type)
+}
+
+// decodeNumbers returns the whole numbers from a list decoded from JSON, where they're
+// float64s, or YAML, where they're ints
+func decodeNumbers(value any, max uint64) ([]uint64, error) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("value was not a list: %#v", value)
+	}
+
+	numbers := make([]uint64, len(items))
+	for i, item := range items {
+		var n float64
+		switch item := item.(type) {
+		case float64:
+			n = item
+		case int:
+			n = float64(item)
+		default:
+			return nil, fmt.Errorf("item was not a number: %#v", item)
+		}
+
+		if n < 0 || n > float64(max) || n != math.Trunc(n) {
+			return nil, fmt.Errorf("item %v is out of range", item)
+		}
+		numbers[i] = uint64(n)
+	}
+
+	return numbers, nil
+}
+
+func parseRational(s string) (exifcommon.Rational, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return exifcommon.Rational{}, fmt.Errorf("rational %q was not in the form n/d", s)
+	}
+
+	numerator, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return exifcommon.Rational{}, fmt.Errorf("failed to parse rational numerator %q: %w", s, err)
+	}
+	denominator, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return exifcommon.Rational{}, fmt.Errorf("failed to parse rational denominator %q: %w", s, err)
+	}
+
+	return exifcommon.Rational{Numerator: uint32(numerator), Denominator: uint32(denominator)}, nil
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	e, err := v.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(e)
+}
+
+func (v *Value) UnmarshalJSON(b []byte) error {
+	var e *encodedValue
+
+	err := json.Unmarshal(b, &e)
+	if err != nil {
+		return err
+	}
+
+	return v.decode(e)
+}
+
+func (v Value) MarshalYAML() (any, error) {
+	e, err := v.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (v *Value) UnmarshalYAML(node *yaml.Node) error {
+	var e *encodedValue
+
+	err := node.Decode(&e)
+	if err != nil {
+		return err
+	}
+
+	return v.decode(e)
+}
+
+// encodedChange is the serialized form of a Change
+type encodedChange struct {
+	IFDPath string `json:"ifd_path"`
+	Key     string `json:"key"`
+	Value   Value  `json:"value"`
+}
+
+func (c Change) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encodedChange{IFDPath: c.IFDPath, Key: c.Key, Value: Value{V: c.Value}})
+}
+
+func (c *Change) UnmarshalJSON(b []byte) error {
+	var e encodedChange
+
+	err := json.Unmarshal(b, &e)
+	if err != nil {
+		return err
+	}
+
+	*c = Change{IFDPath: e.IFDPath, Key: e.Key, Value: e.Value.V}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package exif
+
+import (
+	"encoding/json"
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestChangeJSON(t *testing.T) {
+	testCases := map[string]struct {
+		Change       Change
+		ExpectedJSON string
+	}{
+		"ascii": {
+			Change:       Change{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 18:57:55"},
+			ExpectedJSON: `{"ifd_path":"IFD/Exif","key":"DateTimeOriginal","value":{"type":"ascii","value":"2022:08:03 18:57:55"}}`,
+		},
+		"rational": {
+			Change: Change{IFDPath: "IFD/GPSInfo", Key: "GPSLatitude", Value: []exifcommon.Rational{
+				{Numerator: 51, Denominator: 1},
+				{Numerator: 34, Denominator: 1},
+				{Numerator: 251, Denominator: 100},
+			}},
+			ExpectedJSON: `{"ifd_path":"IFD/GPSInfo","key":"GPSLatitude","value":{"type":"rational","value":["51/1","34/1","251/100"]}}`,
+		},
+		"byte": {
+			Change:       Change{IFDPath: "IFD/GPSInfo", Key: "GPSVersionID", Value: []byte{2, 3, 0, 0}},
+			ExpectedJSON: `{"ifd_path":"IFD/GPSInfo","key":"GPSVersionID","value":{"type":"byte","value":[2,3,0,0]}}`,
+		},
+		"short": {
+			Change:       Change{IFDPath: "IFD/GPSInfo", Key: "GPSDifferential", Value: []uint16{1}},
+			ExpectedJSON: `{"ifd_path":"IFD/GPSInfo","key":"GPSDifferential","value":{"type":"short","value":[1]}}`,
+		},
+		"undefined": {
+			Change:       Change{IFDPath: "IFD/GPSInfo", Key: "GPSProcessingMethod", Value: CharacterCodeASCII("GPS")},
+			ExpectedJSON: `{"ifd_path":"IFD/GPSInfo","key":"GPSProcessingMethod","value":{"type":"undefined","value":"QVNDSUkAAABHUFM="}}`,
+		},
+		"removal": {
+			Change:       Change{IFDPath: "IFD/Exif", Key: "LensModel"},
+			ExpectedJSON: `{"ifd_path":"IFD/Exif","key":"LensModel","value":null}`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b, err := json.Marshal(testCase.Change)
+			require.NoError(t, err)
+			assert.JSONEq(t, testCase.ExpectedJSON, string(b))
+
+			var c Change
+			err = json.Unmarshal(b, &c)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.Change, c)
+		})
+	}
+}
+
+func TestValueJSONUnsupported(t *testing.T) {
+	_, err := json.Marshal(Value{V: 1.5})
+	require.Error(t, err)
+
+	var v Value
+	err = json.Unmarshal([]byte(`{"type":"float","value":1.5}`), &v)
+	require.Error(t, err)
+}
+
+func TestValueYAML(t *testing.T) {
+	values := []Value{
+		{V: "WGS-84"},
+		{V: []byte{2, 3, 0, 0}},
+		{V: []uint16{65535}},
+		{V: CharacterCodeASCII("GPS")},
+		{V: []exifcommon.Rational{{Numerator: 23, Denominator: 1}}},
+	}
+
+	for _, value := range values {
+		b, err := yaml.Marshal(value)
+		require.NoError(t, err)
+
+		var v Value
+		err = yaml.Unmarshal(b, &v)
+		require.NoError(t, err)
+		assert.Equal(t, value, v)
+	}
+}
+
+func TestValueJSONOutOfRange(t *testing.T) {
+	for _, encoded := range []string{
+		`{"type":"byte","value":[256]}`,
+		`{"type":"byte","value":[-1]}`,
+		`{"type":"short","value":[1.5]}`,
+		`{"type":"short","value":[65536]}`,
+		`{"type":"undefined","value":"not base64!"}`,
+	} {
+		var v Value
+		err := json.Unmarshal([]byte(encoded), &v)
+		assert.Error(t, err, encoded)
+	}
+}
@@ -0,0 +1,182 @@
+package gpx
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// CSVOptions set the columns read from CSV files, columns are found by their name in the
+// header row, ignoring case
+type CSVOptions struct {
+	// Lat, Lon, Ele and Time are the column names for each value, when empty the common
+	// names in csvColumnNames are used. The elevation column is optional unless it's set.
+	Lat  string
+	Lon  string
+	Ele  string
+	Time string
+	// TimeFormat is the Go layout of the times, such as "02/01/2006 15:04:05". When empty,
+	// RFC 3339 times, times in the form "2006-01-02 15:04:05" and Unix timestamps in
+	// seconds are read. Times without a zone are UTC.
+	TimeFormat string
+}
+
+// csvColumnNames are the column names used when no name is set for a column
+var csvColumnNames = map[string][]string{
+	"lat":  {"lat", "latitude"},
+	"lon":  {"lon", "lng", "long", "longitude"},
+	"ele":  {"ele", "elevation", "alt", "altitude"},
+	"time": {"time", "timestamp", "datetime"},
+}
+
+const utf8BOM = "\ufeff"
+
+// csvTimeLayouts are the time layouts tried when no time format is set
+var csvTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// parseCSV reads the rows of CSV data with a header row as a single track segment, rows
+// without a latitude or longitude are skipped
+func parseCSV(r io.Reader, opts ReadOptions) (*gpx.GPX, error) {
+	// spreadsheet programs often start files with a byte order mark, which would
+	// otherwise be read as part of the first column's name
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		br.Discard(len(utf8BOM))
+	}
+
+	reader := csv.NewReader(br)
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV has no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for _, c := range []struct{ column, name string }{
+		{"lat", opts.CSV.Lat},
+		{"lon", opts.CSV.Lon},
+		{"ele", opts.CSV.Ele},
+		{"time", opts.CSV.Time},
+	} {
+		i, found := csvColumn(header, c.column, c.name)
+		// the elevation column is only required when it's named
+		if !found && (c.column != "ele" || c.name != "") {
+			return nil, fmt.Errorf("CSV has no %s", csvColumnDescription(c.column, c.name))
+		}
+		if found {
+			columns[c.column] = i
+		}
+	}
+
+	var segment gpx.GPXTrackSegment
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		if value("lat") == "" || value("lon") == "" {
+			continue
+		}
+
+		var point gpx.GPXPoint
+		point.Latitude, err = strconv.ParseFloat(value("lat"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: failed to parse latitude: %w", line, err)
+		}
+		point.Longitude, err = strconv.ParseFloat(value("lon"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: failed to parse longitude: %w", line, err)
+		}
+		if ele := value("ele"); ele != "" {
+			elevation, err := strconv.ParseFloat(ele, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: failed to parse elevation: %w", line, err)
+			}
+			point.Elevation = *gpx.NewNullableFloat64(elevation)
+		}
+		point.Timestamp, err = parseCSVTime(value("time"), opts.CSV.TimeFormat)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		segment.Points = append(segment.Points, point)
+	}
+
+	return &gpx.GPX{Tracks: []gpx.GPXTrack{{Segments: []gpx.GPXTrackSegment{segment}}}}, nil
+}
+
+// csvColumn returns the index of the column in the header, using the name when set or
+// otherwise the common names for the column
+func csvColumn(header []string, column, name string) (int, bool) {
+	names := csvColumnNames[column]
+	if name != "" {
+		names = []string{name}
+	}
+
+	for _, n := range names {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), n) {
+				return i, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func csvColumnDescription(column, name string) string {
+	if name != "" {
+		return fmt.Sprintf("%s column %q", column, name)
+	}
+
+	return fmt.Sprintf("%s column, expected one of %s", column, strings.Join(csvColumnNames[column], ", "))
+}
+
+func parseCSVTime(value, format string) (time.Time, error) {
+	if format != "" {
+		t, err := time.Parse(format, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse time %q with format %q: %w", value, format, err)
+		}
+		return t.UTC(), nil
+	}
+
+	for _, layout := range csvTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("failed to parse time %q, set a time format for times in other formats", value)
+}
@@ -0,0 +1,122 @@
+package gpx
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestNewGPXDatasetFromDiskCSV(t *testing.T) {
+	testCases := map[string]struct {
+		Path           string
+		Options        CSVOptions
+		ExpectedPoints []gpx.GPXPoint
+	}{
+		"default columns": {
+			Path: "fixtures/points.csv",
+			ExpectedPoints: []gpx.GPXPoint{
+				{
+					Point:     gpx.Point{Latitude: 51.5, Longitude: -0.12, Elevation: *gpx.NewNullableFloat64(10)},
+					Timestamp: time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC),
+				},
+				{
+					Point:     gpx.Point{Latitude: 51.5003, Longitude: -0.12},
+					Timestamp: time.Date(2022, time.August, 3, 9, 0, 30, 0, time.UTC),
+				},
+				{
+					Point:     gpx.Point{Latitude: 51.5006, Longitude: -0.12, Elevation: *gpx.NewNullableFloat64(12)},
+					Timestamp: time.Date(2022, time.August, 3, 9, 1, 0, 0, time.UTC),
+				},
+			},
+		},
+		"byte order mark": {
+			Path: "fixtures/bom.csv",
+			ExpectedPoints: []gpx.GPXPoint{
+				{
+					Point:     gpx.Point{Latitude: 51.5, Longitude: -0.12},
+					Timestamp: time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC),
+				},
+				{
+					Point:     gpx.Point{Latitude: 51.5003, Longitude: -0.12},
+					Timestamp: time.Date(2022, time.August, 3, 9, 0, 30, 0, time.UTC),
+				},
+			},
+		},
+		"mapped columns and time format": {
+			Path: "fixtures/export.csv",
+			Options: CSVOptions{
+				Lat:        "y",
+				Lon:        "x",
+				Ele:        "Height (m)",
+				Time:       "Recorded At",
+				TimeFormat: "02/01/2006 15:04:05",
+			},
+			ExpectedPoints: []gpx.GPXPoint{
+				{
+					Point:     gpx.Point{Latitude: 51.5, Longitude: -0.12, Elevation: *gpx.NewNullableFloat64(10)},
+					Timestamp: time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC),
+				},
+				{
+					Point:     gpx.Point{Latitude: 51.5003, Longitude: -0.12, Elevation: *gpx.NewNullableFloat64(11)},
+					Timestamp: time.Date(2022, time.August, 3, 9, 0, 30, 0, time.UTC),
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDiskWithOptions(ReadOptions{CSV: testCase.Options}, testCase.Path)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.ExpectedPoints, gpxDataset.AllPoints())
+		})
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	testCases := map[string]struct {
+		Data          string
+		Options       CSVOptions
+		ExpectedError string
+	}{
+		"empty": {
+			Data:          "",
+			ExpectedError: "CSV has no header row",
+		},
+		"missing default column": {
+			Data:          "time,lat,x\n2022-08-03T09:00:00Z,51.5,-0.12\n",
+			ExpectedError: "CSV has no lon column, expected one of lon, lng, long, longitude",
+		},
+		"missing mapped column": {
+			Data:          "time,lat,lon\n2022-08-03T09:00:00Z,51.5,-0.12\n",
+			Options:       CSVOptions{Ele: "height"},
+			ExpectedError: `CSV has no ele column "height"`,
+		},
+		"invalid latitude": {
+			Data:          "time,lat,lon\n2022-08-03T09:00:00Z,north,-0.12\n",
+			ExpectedError: "line 2: failed to parse latitude",
+		},
+		"time in another format": {
+			Data:          "time,lat,lon\n03/08/2022 09:00,51.5,-0.12\n",
+			ExpectedError: `line 2: failed to parse time "03/08/2022 09:00", set a time format for times in other formats`,
+		},
+		"time not in the time format": {
+			Data:          "time,lat,lon\n2022-08-03T09:00:00Z,51.5,-0.12\n",
+			Options:       CSVOptions{TimeFormat: "02/01/2006 15:04"},
+			ExpectedError: `line 2: failed to parse time "2022-08-03T09:00:00Z" with format "02/01/2006 15:04"`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseCSV(strings.NewReader(testCase.Data), ReadOptions{CSV: testCase.Options})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.ExpectedError)
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package gpx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// FIT global message numbers and field numbers used when reading positions
+const (
+	fitMessageRecord = 20
+	fitMessageEvent  = 21
+
+	fitFieldTimestamp        = 253
+	fitFieldPositionLat      = 0
+	fitFieldPositionLong     = 1
+	fitFieldAltitude         = 2
+	fitFieldEnhancedAltitude = 78
+	fitFieldEvent            = 0
+	fitFieldEventType        = 1
+
+	fitEventTimer       = 0
+	fitEventTypeStop    = 1
+	fitEventTypeStopAll = 4
+)
+
+// fitEpoch is the time FIT timestamps count seconds from
+var fitEpoch = time.Date(1989, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// fitField is a field in a FIT definition message
+type fitField struct {
+	number byte
+	size   int
+}
+
+// fitDefinition describes the data messages for a local message type
+type fitDefinition struct {
+	global    uint16
+	byteOrder binary.ByteOrder
+	fields    []fitField
+	// developerSize is the total size of the developer fields, which are skipped
+	developerSize int
+}
+
+// parseFIT reads the record messages in FIT data as a track. Timer stop events start a
+// new track segment, like pauses in a recording. Chained FIT files are read in turn.
+func parseFIT(r io.Reader) (*gpx.GPX, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	track := gpx.GPXTrack{}
+	for len(b) > 0 {
+		var n int
+		n, err = parseFITFile(b, &track)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+	}
+
+	return &gpx.GPX{Tracks: []gpx.GPXTrack{track}}, nil
+}
+
+// parseFITFile reads a single FIT file from the start of b into track and returns its
+// length, including the header and CRC
+func parseFITFile(b []byte, track *gpx.GPXTrack) (int, error) {
+	if len(b) < 12 || string(b[8:12]) != ".FIT" {
+		return 0, errors.New("missing FIT file header")
+	}
+	headerSize := int(b[0])
+	dataSize := int(binary.LittleEndian.Uint32(b[4:8]))
+	if headerSize < 12 || len(b) < headerSize+dataSize+2 {
+		return 0, fmt.Errorf("FIT file is truncated, header declares %d bytes of data", dataSize)
+	}
+
+	end := headerSize + dataSize
+	if crc := binary.LittleEndian.Uint16(b[end : end+2]); crc != fitCRC(b[:end]) {
+		return 0, fmt.Errorf("FIT file CRC %#04x does not match data", crc)
+	}
+
+	var (
+		definitions   = map[byte]*fitDefinition{}
+		lastTimestamp uint32
+		segment       gpx.GPXTrackSegment
+	)
+	closeSegment := func() {
+		if len(segment.Points) > 0 {
+			track.Segments = append(track.Segments, segment)
+		}
+		segment = gpx.GPXTrackSegment{}
+	}
+
+	for offset := headerSize; offset < end; {
+		header := b[offset]
+		offset++
+
+		// compressed timestamp headers hold the local message type and the low bits of
+		// the timestamp as an offset from the last full timestamp
+		var local byte
+		var compressedTimestamp *uint32
+		switch {
+		case header&0x80 != 0:
+			local = (header >> 5) & 0x03
+			timeOffset := uint32(header & 0x1f)
+			t := lastTimestamp&^0x1f + timeOffset
+			if timeOffset < lastTimestamp&0x1f {
+				t += 0x20
+			}
+			compressedTimestamp = &t
+		case header&0x40 != 0:
+			definition, n, err := parseFITDefinition(b[offset:end], header&0x20 != 0)
+			if err != nil {
+				return 0, err
+			}
+			definitions[header&0x0f] = definition
+			offset += n
+			continue
+		default:
+			local = header & 0x0f
+		}
+
+		definition, ok := definitions[local]
+		if !ok {
+			return 0, fmt.Errorf("FIT data message for undefined local message type %d", local)
+		}
+
+		values := map[byte][]byte{}
+		for _, f := range definition.fields {
+			if offset+f.size > end {
+				return 0, errors.New("FIT data message is truncated")
+			}
+			values[f.number] = b[offset : offset+f.size]
+			offset += f.size
+		}
+		offset += definition.developerSize
+		if offset > end {
+			return 0, errors.New("FIT data message is truncated")
+		}
+
+		timestamp, ok := fitUint32(values[fitFieldTimestamp], definition.byteOrder)
+		if ok {
+			lastTimestamp = timestamp
+		} else if compressedTimestamp != nil {
+			timestamp, ok = *compressedTimestamp, true
+			lastTimestamp = timestamp
+		}
+
+		switch definition.global {
+		case fitMessageEvent:
+			event, _ := fitUint8(values[fitFieldEvent])
+			eventType, _ := fitUint8(values[fitFieldEventType])
+			if event == fitEventTimer && (eventType == fitEventTypeStop || eventType == fitEventTypeStopAll) {
+				closeSegment()
+			}
+		case fitMessageRecord:
+			lat, latOK := fitSint32(values[fitFieldPositionLat], definition.byteOrder)
+			long, longOK := fitSint32(values[fitFieldPositionLong], definition.byteOrder)
+			// records without a position are from sensors while there was no fix
+			if !ok || !latOK || !longOK {
+				continue
+			}
+
+			point := gpx.GPXPoint{
+				Point: gpx.Point{
+					Latitude:  fromSemicircles(lat),
+					Longitude: fromSemicircles(long),
+				},
+				Timestamp: fitEpoch.Add(time.Duration(timestamp) * time.Second),
+			}
+			if altitude, ok := fitAltitude(values, definition.byteOrder); ok {
+				point.Elevation = *gpx.NewNullableFloat64(altitude)
+			}
+
+			segment.Points = append(segment.Points, point)
+		}
+	}
+	closeSegment()
+
+	return end + 2, nil
+}
+
+// parseFITDefinition parses the content of a definition message, returning its length
+func parseFITDefinition(b []byte, developer bool) (*fitDefinition, int, error) {
+	if len(b) < 5 {
+		return nil, 0, errors.New("FIT definition message is truncated")
+	}
+
+	definition := &fitDefinition{byteOrder: binary.LittleEndian}
+	if b[1] == 1 {
+		definition.byteOrder = binary.BigEndian
+	}
+	definition.global = definition.byteOrder.Uint16(b[2:4])
+
+	fieldCount := int(b[4])
+	n := 5 + fieldCount*3
+	if len(b) < n {
+		return nil, 0, errors.New("FIT definition message is truncated")
+	}
+	for i := 0; i < fieldCount; i++ {
+		f := b[5+i*3:]
+		definition.fields = append(definition.fields, fitField{number: f[0], size: int(f[1])})
+	}
+
+	if developer {
+		if len(b) < n+1 {
+			return nil, 0, errors.New("FIT definition message is truncated")
+		}
+		developerCount := int(b[n])
+		n++
+		if len(b) < n+developerCount*3 {
+			return nil, 0, errors.New("FIT definition message is truncated")
+		}
+		for i := 0; i < developerCount; i++ {
+			definition.developerSize += int(b[n+i*3+1])
+		}
+		n += developerCount * 3
+	}
+
+	return definition, n, nil
+}
+
+// fitAltitude returns the altitude in meters of a record, preferring the enhanced
+// altitude field which has a larger range
+func fitAltitude(values map[byte][]byte, byteOrder binary.ByteOrder) (float64, bool) {
+	if v, ok := fitUint32(values[fitFieldEnhancedAltitude], byteOrder); ok {
+		return float64(v)/5 - 500, true
+	}
+
+	b := values[fitFieldAltitude]
+	if len(b) != 2 {
+		return 0, false
+	}
+	v := byteOrder.Uint16(b)
+	if v == math.MaxUint16 {
+		return 0, false
+	}
+
+	return float64(v)/5 - 500, true
+}
+
+// fitUint8, fitUint32 and fitSint32 decode field values, they return false when the field
+// is missing or holds the invalid value for its type
+func fitUint8(b []byte) (uint8, bool) {
+	if len(b) != 1 || b[0] == math.MaxUint8 {
+		return 0, false
+	}
+
+	return b[0], true
+}
+
+func fitUint32(b []byte, byteOrder binary.ByteOrder) (uint32, bool) {
+	if len(b) != 4 {
+		return 0, false
+	}
+	v := byteOrder.Uint32(b)
+
+	return v, v != math.MaxUint32
+}
+
+func fitSint32(b []byte, byteOrder binary.ByteOrder) (int32, bool) {
+	if len(b) != 4 {
+		return 0, false
+	}
+	v := int32(byteOrder.Uint32(b))
+
+	return v, v != math.MaxInt32
+}
+
+// fromSemicircles returns the degrees for a FIT coordinate, where 2^31 semicircles are
+// 180 degrees
+func fromSemicircles(v int32) float64 {
+	return float64(v) * 180 / (1 << 31)
+}
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xcc01, 0xd801, 0x1400, 0xf001, 0x3c00, 0x2800, 0xe401,
+	0xa001, 0x6c00, 0x7800, 0xb401, 0x5000, 0x9c01, 0x8801, 0x4400,
+}
+
+// fitCRC returns the CRC-16 used by FIT files
+func fitCRC(b []byte) uint16 {
+	var crc uint16
+	for _, v := range b {
+		tmp := fitCRCTable[crc&0xf]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ fitCRCTable[v&0xf]
+
+		tmp = fitCRCTable[crc&0xf]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ fitCRCTable[(v>>4)&0xf]
+	}
+
+	return crc
+}
@@ -0,0 +1,124 @@
+package gpx
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFIT(t *testing.T) {
+	b, err := os.ReadFile("fixtures/ride.fit")
+	require.NoError(t, err)
+
+	type expectedPoint struct {
+		Time      time.Time
+		Latitude  float64
+		Longitude float64
+		Elevation float64
+	}
+
+	start := time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		Data             []byte
+		ExpectedSegments [][]expectedPoint
+		ExpectedError    string
+	}{
+		"activity": {
+			Data: b,
+			ExpectedSegments: [][]expectedPoint{
+				{
+					{Time: start, Latitude: 51.5, Longitude: -0.12, Elevation: 10},
+					{Time: start.Add(2 * time.Second), Latitude: 51.5002, Longitude: -0.12, Elevation: 12},
+					// compressed timestamps, including one which rolls over
+					{Time: start.Add(5 * time.Second), Latitude: 51.5005, Longitude: -0.12, Elevation: 13},
+					{Time: start.Add(22 * time.Second), Latitude: 51.501, Longitude: -0.12, Elevation: 14},
+				},
+				{
+					{Time: start.Add(10 * time.Minute), Latitude: 51.51, Longitude: -0.1, Elevation: 30},
+					{Time: start.Add(10*time.Minute + 10*time.Second), Latitude: 51.51, Longitude: -0.09, Elevation: 30},
+				},
+			},
+		},
+		"chained files": {
+			Data: append(append([]byte{}, b...), b...),
+			ExpectedSegments: [][]expectedPoint{
+				make([]expectedPoint, 4), make([]expectedPoint, 2),
+				make([]expectedPoint, 4), make([]expectedPoint, 2),
+			},
+		},
+		"invalid CRC": {
+			Data:          append(append([]byte{}, b[:len(b)-1]...), b[len(b)-1]^0xff),
+			ExpectedError: "does not match data",
+		},
+		"truncated": {
+			Data:          b[:100],
+			ExpectedError: "FIT file is truncated",
+		},
+		"not a FIT file": {
+			Data:          []byte("<gpx></gpx>"),
+			ExpectedError: "missing FIT file header",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			data, err := parseFIT(bytes.NewReader(testCase.Data))
+			if testCase.ExpectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Len(t, data.Tracks, 1)
+			segments := data.Tracks[0].Segments
+			require.Len(t, segments, len(testCase.ExpectedSegments))
+
+			for i, expectedPoints := range testCase.ExpectedSegments {
+				require.Len(t, segments[i].Points, len(expectedPoints))
+				for j, expected := range expectedPoints {
+					if expected.Time.IsZero() {
+						continue
+					}
+
+					p := segments[i].Points[j]
+					assert.Equal(t, expected.Time, p.Timestamp)
+					assert.InDelta(t, expected.Latitude, p.Latitude, 0.0000001)
+					assert.InDelta(t, expected.Longitude, p.Longitude, 0.0000001)
+					assert.InDelta(t, expected.Elevation, p.Elevation.Value(), 0.01)
+				}
+			}
+		})
+	}
+}
+
+func TestFromSemicircles(t *testing.T) {
+	testCases := map[string]struct {
+		Value    int32
+		Expected float64
+	}{
+		"zero": {
+			Value:    0,
+			Expected: 0,
+		},
+		"north": {
+			Value:    1 << 30,
+			Expected: 90,
+		},
+		"west": {
+			Value:    -1 << 30,
+			Expected: -90,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testCase.Expected, fromSemicircles(testCase.Value))
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package gpx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// sniffLength is how much of a file is read to detect its format
+const sniffLength = 512
+
+// ReadOptions control how files are read into a GPXDataset
+type ReadOptions struct {
+	// MaxAccuracy is the largest accuracy radius in meters of the points which are
+	// loaded, for formats which record accuracy. Points which are less accurate are
+	// dropped, zero loads all points.
+	MaxAccuracy float64
+	// CSV sets the columns read from CSV files
+	CSV CSVOptions
+}
+
+// keep returns true if a point with the accuracy radius in meters should be loaded,
+// accuracy is nil when it wasn't recorded
+func (o ReadOptions) keep(accuracy *float64) bool {
+	return o.MaxAccuracy <= 0 || accuracy == nil || *accuracy <= o.MaxAccuracy
+}
+
+// format is a file format containing timed points which can be loaded into a GPXDataset
+type format struct {
+	name string
+	// extensions are the lower case file extensions used for the format
+	extensions []string
+	// sniff reports whether data starting with head is in the format, it's nil for
+	// formats which are only detected by their extension
+	sniff func(head []byte) bool
+	// parse reads the points in the data as GPX tracks
+	parse func(r io.Reader, opts ReadOptions) (*gpx.GPX, error)
+}
+
+// formats are the supported file formats, in the order they're tried when sniffing
+var formats = []format{
+	{
+		name:       "GPX",
+		extensions: []string{".gpx"},
+		sniff:      func(head []byte) bool { return bytes.Contains(head, []byte("<gpx")) },
+		parse:      withoutOptions(parseGPX),
+	},
+	{
+		name:       "KML",
+		extensions: []string{".kml"},
+		sniff:      func(head []byte) bool { return bytes.Contains(head, []byte("<kml")) },
+		parse:      withoutOptions(parseKML),
+	},
+	{
+		name:       "KMZ",
+		extensions: []string{".kmz"},
+		sniff:      func(head []byte) bool { return bytes.HasPrefix(head, []byte("PK\x03\x04")) },
+		parse:      withoutOptions(parseKMZ),
+	},
+	{
+		name:       "TCX",
+		extensions: []string{".tcx"},
+		sniff:      func(head []byte) bool { return bytes.Contains(head, []byte("<TrainingCenterDatabase")) },
+		parse:      withoutOptions(parseTCX),
+	},
+	{
+		name:       "FIT",
+		extensions: []string{".fit"},
+		sniff:      func(head []byte) bool { return len(head) >= 12 && string(head[8:12]) == ".FIT" },
+		parse:      withoutOptions(parseFIT),
+	},
+	{
+		name:       "NMEA",
+		extensions: []string{".nmea"},
+		sniff:      sniffNMEA,
+		parse:      withoutOptions(parseNMEA),
+	},
+	{
+		name:  "Takeout Records",
+		sniff: func(head []byte) bool { return jsonObjectWithKey(head, "locations") },
+		parse: parseTakeoutRecords,
+	},
+	{
+		name: "Takeout Timeline",
+		sniff: func(head []byte) bool {
+			return jsonObjectWithKey(head, "semanticSegments") || jsonObjectWithKey(head, "rawSignals")
+		},
+		parse: parseTakeoutTimeline,
+	},
+	{
+		name:       "GeoJSON",
+		extensions: []string{".geojson"},
+		sniff: func(head []byte) bool {
+			return jsonObjectWithKey(head, "type") &&
+				(bytes.Contains(head, []byte(`"Feature`)) || bytes.Contains(head, []byte(`"coordinates"`)))
+		},
+		parse: withoutOptions(parseGeoJSON),
+	},
+	{
+		name:       "CSV",
+		extensions: []string{".csv"},
+		parse:      parseCSV,
+	},
+}
+
+// withoutOptions adapts a parse function for a format which has no read options
+func withoutOptions(parse func(r io.Reader) (*gpx.GPX, error)) func(io.Reader, ReadOptions) (*gpx.GPX, error) {
+	return func(r io.Reader, _ ReadOptions) (*gpx.GPX, error) {
+		return parse(r)
+	}
+}
+
+// jsonObjectWithKey reports whether head is the start of a JSON object containing key
+func jsonObjectWithKey(head []byte, key string) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(head), []byte("{")) && bytes.Contains(head, []byte(`"`+key+`"`))
+}
+
+// formatForExtension returns the format using the file's extension, or nil if the
+// extension isn't known
+func formatForExtension(path string) *format {
+	ext := strings.ToLower(filepath.Ext(path))
+	for i, f := range formats {
+		for _, e := range f.extensions {
+			if e == ext {
+				return &formats[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// sniffFormat returns the format of the data in r, or nil if it's not recognized. The
+// returned reader reads all of the data, including the part used to detect the format.
+func sniffFormat(r io.Reader) (*format, io.Reader) {
+	br := bufio.NewReaderSize(r, sniffLength)
+	head, _ := br.Peek(sniffLength)
+
+	for i, f := range formats {
+		if f.sniff != nil && f.sniff(head) {
+			return &formats[i], br
+		}
+	}
+
+	return nil, br
+}
+
+// parseFile parses a file in the format given by its extension, or by its contents when
+// the extension isn't known. ok is false when the format isn't recognized, which includes
+// zip archives without a KML document when they're only recognized by their contents.
+func parseFile(path string, r io.Reader, opts ReadOptions) (data *gpx.GPX, ok bool, err error) {
+	f := formatForExtension(path)
+	sniffed := f == nil
+	if sniffed {
+		f, r = sniffFormat(r)
+		if f == nil {
+			return nil, false, nil
+		}
+	}
+
+	data, err = f.parse(r, opts)
+	if sniffed && errors.Is(err, errNoKMLDocument) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to parse %s data from file %s: %w", f.name, path, err)
+	}
+
+	return data, true, nil
+}
+
+func parseGPX(r io.Reader) (*gpx.GPX, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return gpx.ParseBytes(b)
+}
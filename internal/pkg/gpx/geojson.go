@@ -0,0 +1,173 @@
+package gpx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// geoJSONObject is a GeoJSON FeatureCollection, Feature or geometry, only the members
+// used for timed points are decoded
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Features    []geoJSONObject `json:"features"`
+	Geometry    *geoJSONObject  `json:"geometry"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Properties  struct {
+		// CoordTimes holds the times of the positions of a LineString, or of each line of
+		// a MultiLineString
+		CoordTimes json.RawMessage `json:"coordTimes"`
+		Time       string          `json:"time"`
+		Timestamp  string          `json:"timestamp"`
+	} `json:"properties"`
+}
+
+// parseGeoJSON reads the timed positions in GeoJSON features. LineString features with a
+// coordTimes property become track segments, as does each line of a MultiLineString.
+// Point features with a time or timestamp property are read as a single segment. Other
+// geometries and features without times are ignored.
+func parseGeoJSON(r io.Reader) (*gpx.GPX, error) {
+	var root geoJSONObject
+	err := json.NewDecoder(r).Decode(&root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoJSON: %w", err)
+	}
+
+	features := []geoJSONObject{root}
+	if root.Type == "FeatureCollection" {
+		features = root.Features
+	}
+
+	var track gpx.GPXTrack
+	var points gpx.GPXTrackSegment
+	for i, f := range features {
+		if f.Type != "Feature" || f.Geometry == nil {
+			continue
+		}
+
+		switch f.Geometry.Type {
+		case "Point":
+			timestamp := f.Properties.Time
+			if timestamp == "" {
+				timestamp = f.Properties.Timestamp
+			}
+			if timestamp == "" {
+				continue
+			}
+
+			var coordinates []float64
+			err = json.Unmarshal(f.Geometry.Coordinates, &coordinates)
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: failed to read Point coordinates: %w", i, err)
+			}
+
+			point, err := geoJSONPoint(coordinates, timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			points.Points = append(points.Points, point)
+		case "LineString":
+			if f.Properties.CoordTimes == nil {
+				continue
+			}
+
+			var coordinates [][]float64
+			var times []string
+			err = unmarshalGeoJSONLines(f, &coordinates, &times)
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+
+			segment, err := geoJSONSegment(coordinates, times)
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			track.Segments = append(track.Segments, segment)
+		case "MultiLineString":
+			if f.Properties.CoordTimes == nil {
+				continue
+			}
+
+			var coordinates [][][]float64
+			var times [][]string
+			err = unmarshalGeoJSONLines(f, &coordinates, &times)
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			if len(coordinates) != len(times) {
+				return nil, fmt.Errorf("feature %d: MultiLineString has %d lines but coordTimes has %d", i, len(coordinates), len(times))
+			}
+
+			for j := range coordinates {
+				segment, err := geoJSONSegment(coordinates[j], times[j])
+				if err != nil {
+					return nil, fmt.Errorf("feature %d: line %d: %w", i, j, err)
+				}
+				track.Segments = append(track.Segments, segment)
+			}
+		}
+	}
+
+	if len(points.Points) > 0 {
+		track.Segments = append(track.Segments, points)
+	}
+
+	return &gpx.GPX{Tracks: []gpx.GPXTrack{track}}, nil
+}
+
+func unmarshalGeoJSONLines(f geoJSONObject, coordinates, times interface{}) error {
+	err := json.Unmarshal(f.Geometry.Coordinates, coordinates)
+	if err != nil {
+		return fmt.Errorf("failed to read %s coordinates: %w", f.Geometry.Type, err)
+	}
+	err = json.Unmarshal(f.Properties.CoordTimes, times)
+	if err != nil {
+		return fmt.Errorf("failed to read coordTimes: %w", err)
+	}
+
+	return nil
+}
+
+// geoJSONSegment returns a segment from the positions of a line and their times
+func geoJSONSegment(coordinates [][]float64, times []string) (gpx.GPXTrackSegment, error) {
+	if len(coordinates) != len(times) {
+		return gpx.GPXTrackSegment{}, fmt.Errorf("line has %d positions but coordTimes has %d", len(coordinates), len(times))
+	}
+
+	var segment gpx.GPXTrackSegment
+	for i := range coordinates {
+		point, err := geoJSONPoint(coordinates[i], times[i])
+		if err != nil {
+			return gpx.GPXTrackSegment{}, err
+		}
+		segment.Points = append(segment.Points, point)
+	}
+
+	return segment, nil
+}
+
+// geoJSONPoint returns a point from a GeoJSON position of longitude, latitude and an
+// optional elevation
+func geoJSONPoint(position []float64, timestamp string) (gpx.GPXPoint, error) {
+	if len(position) < 2 {
+		return gpx.GPXPoint{}, fmt.Errorf("position %v does not have a longitude and latitude", position)
+	}
+
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return gpx.GPXPoint{}, fmt.Errorf("failed to parse time %q: %w", timestamp, err)
+	}
+
+	point := gpx.GPXPoint{
+		Point:     gpx.Point{Latitude: position[1], Longitude: position[0]},
+		Timestamp: t.UTC(),
+	}
+	if len(position) > 2 {
+		point.Elevation = *gpx.NewNullableFloat64(position[2])
+	}
+
+	return point, nil
+}
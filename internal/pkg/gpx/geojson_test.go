@@ -0,0 +1,86 @@
+package gpx
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestParseGeoJSON(t *testing.T) {
+	file, err := os.Open("fixtures/track.geojson")
+	require.NoError(t, err)
+	defer file.Close()
+
+	data, err := parseGeoJSON(file)
+	require.NoError(t, err)
+
+	require.Len(t, data.Tracks, 1)
+	segments := data.Tracks[0].Segments
+
+	// the LineString, each line of the MultiLineString, and the timed Points
+	require.Len(t, segments, 4)
+	require.Len(t, segments[0].Points, 3)
+	require.Len(t, segments[1].Points, 2)
+	require.Len(t, segments[2].Points, 1)
+	require.Len(t, segments[3].Points, 2)
+
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC), segments[0].Points[0].Timestamp)
+	assert.Equal(t, gpx.Point{
+		Latitude:  51.5,
+		Longitude: -0.12,
+		Elevation: *gpx.NewNullableFloat64(10),
+	}, segments[0].Points[0].Point)
+
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 1, 0, 0, time.UTC), segments[0].Points[2].Timestamp)
+	assert.False(t, segments[0].Points[2].Elevation.NotNull())
+
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 20, 0, 0, time.UTC), segments[2].Points[0].Timestamp)
+
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 31, 0, 0, time.UTC), segments[3].Points[1].Timestamp)
+	assert.Equal(t, gpx.Point{Latitude: 51.531, Longitude: -0.07}, segments[3].Points[1].Point)
+}
+
+func TestParseGeoJSONErrors(t *testing.T) {
+	testCases := map[string]struct {
+		Data          string
+		ExpectedError string
+	}{
+		"coordTimes length mismatch": {
+			Data: `{"type": "Feature", "properties": {"coordTimes": ["2022-08-03T09:00:00Z"]},
+				"geometry": {"type": "LineString", "coordinates": [[-0.12, 51.5], [-0.12, 51.6]]}}`,
+			ExpectedError: "feature 0: line has 2 positions but coordTimes has 1",
+		},
+		"MultiLineString coordTimes length mismatch": {
+			Data: `{"type": "Feature", "properties": {"coordTimes": [["2022-08-03T09:00:00Z"]]},
+				"geometry": {"type": "MultiLineString", "coordinates": [[[-0.12, 51.5]], [[-0.12, 51.6]]]}}`,
+			ExpectedError: "feature 0: MultiLineString has 2 lines but coordTimes has 1",
+		},
+		"invalid time": {
+			Data: `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"time": "yesterday"},
+				"geometry": {"type": "Point", "coordinates": [-0.12, 51.5]}}]}`,
+			ExpectedError: `feature 0: failed to parse time "yesterday"`,
+		},
+		"position without latitude": {
+			Data: `{"type": "Feature", "properties": {"time": "2022-08-03T09:00:00Z"},
+				"geometry": {"type": "Point", "coordinates": [-0.12]}}`,
+			ExpectedError: "feature 0: position [-0.12] does not have a longitude and latitude",
+		},
+		"invalid JSON": {
+			Data:          `{"type": "Feature"`,
+			ExpectedError: "failed to read GeoJSON",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseGeoJSON(strings.NewReader(testCase.Data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.ExpectedError)
+		})
+	}
+}
@@ -7,28 +7,86 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 	"time"
 )
 
+// DefaultMaxGap is the MaxGap used when none is set on a GPXDataset
+const DefaultMaxGap = 24 * time.Hour
+
+// GPXDataset is an index of the points in a set of GPX files. Lookups don't modify the
+// dataset, so it's safe for concurrent use once its options have been set.
 type GPXDataset struct {
+	// Interpolation is the method used to determine positions between recorded points,
+	// the zero value is treated as InterpolationNearest
+	Interpolation Interpolation
+	// MaxGap is the largest time difference allowed between a requested time and the
+	// recorded point used for it, the zero value is treated as DefaultMaxGap.
+	// Interpolation is only used between points no more than MaxGap apart.
+	MaxGap time.Duration
+	// StationaryRadius is the distance in meters within which the last point before a gap
+	// between track segments and the first point after it are treated as the same place.
+	// When set, times inside such gaps match the last point before the gap, otherwise
+	// times inside gaps between segments have no fix.
+	StationaryRadius float64
+
 	data []*gpx.GPX
+	// points is the index of all points in data, sorted by time with duplicate
+	// timestamps removed. It is built once when the dataset is loaded.
+	points []gpx.GPXPoint
+	// segments holds the index into segmentRanges of the segment for each point in points
+	segments []int
+	// segmentRanges holds the time range covered by each track segment in data
+	segmentRanges []segmentRange
 }
 
-func (g *GPXDataset) AllPoints() []gpx.GPXPoint {
-	var allPoints []gpx.GPXPoint
+type segmentRange struct {
+	start, end time.Time
+}
 
-	for _, d := range g.data {
-		for _, track := range d.Tracks {
-			for _, segment := range track.Segments {
-				for _, point := range segment.Points {
-					allPoints = append(allPoints, point)
-				}
-			}
-		}
+func (r segmentRange) contains(t time.Time) bool {
+	return !t.Before(r.start) && !t.After(r.end)
+}
+
+// Match is the result of finding a position in the dataset for a given time
+type Match struct {
+	// Point is the position for the requested time, it is only a recorded point
+	// when Method is InterpolationNearest
+	Point gpx.GPXPoint
+	// Method is the interpolation method which produced Point
+	Method Interpolation
+	// Nearest is the recorded point closest in time to the requested time
+	Nearest gpx.GPXPoint
+}
+
+// NoFixError is returned when there is no recorded point close enough in time
+// to the requested time to determine a position
+type NoFixError struct {
+	// Time is the requested time
+	Time time.Time
+	// Gap is the time between the requested time and the nearest recorded point
+	Gap time.Duration
+	// MaxGap is the tolerance that Gap exceeded
+	MaxGap time.Duration
+	// OutOfRange is true when the requested time is before or after all recorded points
+	OutOfRange bool
+	// BetweenSegments is true when the requested time is in a gap between track segments
+	BetweenSegments bool
+}
+
+func (e *NoFixError) Error() string {
+	if e.OutOfRange {
+		return fmt.Sprintf("out of range of loaded files: %v is %s from the nearest point, max gap is %s", e.Time, e.Gap, e.MaxGap)
 	}
+	if e.BetweenSegments {
+		return fmt.Sprintf("no fix for %v: time is between track segments, nearest point is %s away", e.Time, e.Gap)
+	}
+	return fmt.Sprintf("no fix for %v: nearest point is %s away, max gap is %s", e.Time, e.Gap, e.MaxGap)
+}
 
-	sort.Slice(allPoints, func(i, j int) bool { return allPoints[i].Timestamp.Before(allPoints[j].Timestamp) })
+// AllPoints returns all the points in the dataset sorted by time
+func (g *GPXDataset) AllPoints() []gpx.GPXPoint {
+	allPoints := make([]gpx.GPXPoint, len(g.points))
+	copy(allPoints, g.points)
 
 	return allPoints
 }
@@ -36,145 +94,254 @@ func (g *GPXDataset) AllPoints() []gpx.GPXPoint {
 // InRange returns true if the given time is within the range of the loaded GPX data.
 // The second return value is whether the time is before or after the range
 func (g *GPXDataset) InRange(t time.Time) (bool, bool) {
-	if len(g.data) < 1 {
+	if len(g.points) < 1 {
 		return false, false
 	}
 
-	allPoints := g.AllPoints()
-
-	if len(allPoints) < 1 {
-		return false, false
-	}
-
-	if t.Before(allPoints[0].Timestamp) {
+	if t.Before(g.points[0].Timestamp) {
 		return false, true
 	}
-	if t.After(allPoints[len(allPoints)-1].Timestamp) {
+	if t.After(g.points[len(g.points)-1].Timestamp) {
 		return false, false
 	}
 
 	return true, false
 }
 
-// AtTime returns the closest point for a given time.
-// If the time is not in the range of the points, then the first or last point is used.
-// However, the offset of such matches are limited to 24hrs.
+// buildIndex populates the sorted point index from the loaded GPX data, when
+// points share a timestamp only the first loaded is kept
+func (g *GPXDataset) buildIndex() {
+	type indexedPoint struct {
+		point   gpx.GPXPoint
+		segment int
+	}
+
+	var points []indexedPoint
+	g.segmentRanges = nil
+
+	for _, d := range g.data {
+		for _, track := range d.Tracks {
+			for _, segment := range track.Segments {
+				if len(segment.Points) == 0 {
+					continue
+				}
+
+				r := segmentRange{start: segment.Points[0].Timestamp, end: segment.Points[0].Timestamp}
+				for _, p := range segment.Points {
+					points = append(points, indexedPoint{point: p, segment: len(g.segmentRanges)})
+					if p.Timestamp.Before(r.start) {
+						r.start = p.Timestamp
+					}
+					if p.Timestamp.After(r.end) {
+						r.end = p.Timestamp
+					}
+				}
+				g.segmentRanges = append(g.segmentRanges, r)
+			}
+		}
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].point.Timestamp.Before(points[j].point.Timestamp) })
+
+	g.points, g.segments = nil, nil
+	for i, p := range points {
+		if i > 0 && p.point.Timestamp.Equal(points[i-1].point.Timestamp) {
+			continue
+		}
+		g.points = append(g.points, p.point)
+		g.segments = append(g.segments, p.segment)
+	}
+}
+
+// AtTime returns the position for a given time, see Match for details.
 func (g *GPXDataset) AtTime(t time.Time) (gpx.GPXPoint, error) {
+	m, err := g.Match(t)
+	if err != nil {
+		return gpx.GPXPoint{}, err
+	}
+
+	return m.Point, nil
+}
+
+// Match returns the position for a given time using the dataset's interpolation method.
+// If the time is not in the range of the points, then the first or last point is used.
+// Matches are limited to points within the dataset's MaxGap of the time, a *NoFixError is
+// returned otherwise. Positions are never interpolated between track segments, see
+// StationaryRadius for how times in gaps between segments are handled.
+func (g *GPXDataset) Match(t time.Time) (Match, error) {
+	method, err := ParseInterpolation(string(g.Interpolation))
+	if err != nil {
+		return Match{}, err
+	}
+
+	maxGap := g.MaxGap
+	if maxGap <= 0 {
+		maxGap = DefaultMaxGap
+	}
+
+	allPoints := g.points
+
 	inRange, before := g.InRange(t)
 	if !inRange {
-		allPoints := g.AllPoints()
 		if len(allPoints) == 0 {
-			return gpx.GPXPoint{}, fmt.Errorf("no points in dataset")
+			return Match{}, fmt.Errorf("no points in dataset")
 		}
+
+		candidatePoint := allPoints[len(allPoints)-1]
+		gap := t.Sub(candidatePoint.Timestamp)
 		if before {
-			candidatePoint := allPoints[0]
-			if candidatePoint.Timestamp.Sub(t) < 24*time.Hour {
-				return candidatePoint, nil
-			}
+			candidatePoint = allPoints[0]
+			gap = candidatePoint.Timestamp.Sub(t)
+		}
 
-			return gpx.GPXPoint{}, fmt.Errorf("out of range of loaded files: %v", t)
-		} else {
-			candidatePoint := allPoints[len(allPoints)-1]
-			if t.Sub(candidatePoint.Timestamp) < 24*time.Hour {
-				return candidatePoint, nil
-			}
-			return gpx.GPXPoint{}, fmt.Errorf("out of range of loaded files: %v", t)
+		if gap > maxGap {
+			return Match{}, &NoFixError{Time: t, Gap: gap, MaxGap: maxGap, OutOfRange: true}
 		}
-	}
 
-	var closestPoint gpx.GPXPoint
+		return Match{Point: candidatePoint, Method: InterpolationNearest, Nearest: candidatePoint}, nil
+	}
 
-	minDiff := time.Hour * 24 * 365
-	match := false
+	// find the recorded points either side of the time
+	next := sort.Search(len(allPoints), func(i int) bool { return !allPoints[i].Timestamp.Before(t) })
+	if next == len(allPoints) {
+		return Match{}, fmt.Errorf("no match found for time %s", t)
+	}
+	previous := next
+	if !allPoints[next].Timestamp.Equal(t) && next > 0 {
+		previous = next - 1
+	}
 
-	for _, d := range g.data {
-		for _, track := range d.Tracks {
-			for _, segment := range track.Segments {
-				for _, point := range segment.Points {
+	m := Match{Method: InterpolationNearest, Nearest: allPoints[previous]}
+	gap := t.Sub(allPoints[previous].Timestamp)
+	if nextGap := allPoints[next].Timestamp.Sub(t); nextGap < gap {
+		m.Nearest = allPoints[next]
+		gap = nextGap
+	}
+	m.Point = m.Nearest
+
+	// when the bracketing points are from different segments, the time is only
+	// matched if one of the segments covers it or the device didn't move in the gap
+	sameSegment := g.segments[previous] == g.segments[next]
+	if !sameSegment &&
+		!g.segmentRanges[g.segments[previous]].contains(t) &&
+		!g.segmentRanges[g.segments[next]].contains(t) {
+		if g.StationaryRadius <= 0 || gpx.HaversineDistance(
+			allPoints[previous].Latitude, allPoints[previous].Longitude,
+			allPoints[next].Latitude, allPoints[next].Longitude,
+		) > g.StationaryRadius {
+			return Match{}, &NoFixError{Time: t, Gap: gap, MaxGap: maxGap, BetweenSegments: true}
+		}
 
-					var diff time.Duration
+		// the last point before the gap is used, so it's reported as the nearest and
+		// it must be within the max gap of the time
+		m.Point = allPoints[previous]
+		m.Nearest = allPoints[previous]
+		if stationaryGap := t.Sub(allPoints[previous].Timestamp); stationaryGap > maxGap {
+			return Match{}, &NoFixError{Time: t, Gap: stationaryGap, MaxGap: maxGap}
+		}
 
-					if point.Timestamp.Before(t) {
-						diff = t.Sub(point.Timestamp)
-					} else {
-						diff = point.Timestamp.Sub(t)
-					}
+		return m, nil
+	}
 
-					if diff < minDiff {
-						match = true
-						minDiff = diff
-						closestPoint = point
-					}
-				}
-			}
-		}
+	if gap > maxGap {
+		return Match{}, &NoFixError{Time: t, Gap: gap, MaxGap: maxGap}
 	}
 
-	if !match {
-		return gpx.GPXPoint{}, fmt.Errorf("no match found for time %s", t)
+	span := allPoints[next].Timestamp.Sub(allPoints[previous].Timestamp)
+	if method != InterpolationNearest && previous != next && sameSegment && span <= maxGap {
+		m.Point = interpolate(method, allPoints[previous], allPoints[next], t)
+		m.Method = method
 	}
 
-	return closestPoint, nil
+	return m, nil
 }
 
+// NewGPXDatasetFromDisk loads the points in files and directories of files. Files are
+// read in the supported formats, such as GPX, KML, FIT, NMEA, GeoJSON or CSV, based on
+// their extension, or their contents when the extension isn't known. In directories,
+// files in formats which aren't recognized are skipped.
 func NewGPXDatasetFromDisk(paths ...string) (GPXDataset, error) {
-	var files []string
+	return NewGPXDatasetFromDiskWithOptions(ReadOptions{}, paths...)
+}
+
+// NewGPXDatasetFromDiskWithOptions loads the points in files and directories of files
+// like NewGPXDatasetFromDisk, using opts when reading them
+func NewGPXDatasetFromDiskWithOptions(opts ReadOptions, paths ...string) (GPXDataset, error) {
+	ds := GPXDataset{}
+
 	for _, path := range paths {
-		file, err := os.Open(path)
+		fileInfo, err := os.Stat(path)
 		if err != nil {
-			return GPXDataset{}, fmt.Errorf("failed to open %s: %w", path, err)
+			return GPXDataset{}, fmt.Errorf("failed to stat %s: %w", path, err)
 		}
-		defer file.Close()
 
-		fileInfo, err := file.Stat()
+		if !fileInfo.IsDir() {
+			data, err := parseFileFromDisk(path, opts)
+			if err != nil {
+				return GPXDataset{}, err
+			}
+			if data == nil {
+				return GPXDataset{}, fmt.Errorf("format of %s is not supported", path)
+			}
+			ds.data = append(ds.data, data)
+			continue
+		}
+
+		dirFiles, err := os.ReadDir(path)
 		if err != nil {
-			return GPXDataset{}, fmt.Errorf("failed to stat %s: %w", path, err)
+			return GPXDataset{}, fmt.Errorf("failed to read dir %s: %w", path, err)
 		}
+		for _, f := range dirFiles {
+			if f.IsDir() {
+				continue
+			}
 
-		if fileInfo.IsDir() {
-			dirFiles, err := os.ReadDir(path)
+			data, err := parseFileFromDisk(filepath.Join(path, f.Name()), opts)
 			if err != nil {
-				return GPXDataset{}, fmt.Errorf("failed to read dir %s: %w", path, err)
+				return GPXDataset{}, err
 			}
-			for _, f := range dirFiles {
-				if f.IsDir() || !strings.HasSuffix(f.Name(), ".gpx") {
-					continue
-				}
-				files = append(files, filepath.Join(path, f.Name()))
+			if data != nil {
+				ds.data = append(ds.data, data)
 			}
-		} else {
-			files = append(files, path)
 		}
 	}
 
-	ds := GPXDataset{}
+	ds.buildIndex()
 
-	for _, f := range files {
-		data, err := gpx.ParseFile(f)
-		if err != nil {
-			return GPXDataset{}, fmt.Errorf("failed to parse gpx data from file %s: %w", f, err)
-		}
+	return ds, nil
+}
 
-		ds.data = append(ds.data, data)
+// parseFileFromDisk parses the file at path, the data is nil when the format isn't
+// recognized
+func parseFileFromDisk(path string, opts ReadOptions) (*gpx.GPX, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
 	}
+	defer file.Close()
 
-	return ds, nil
+	data, _, err := parseFile(path, file, opts)
+
+	return data, err
 }
 
+// NewGPXDatasetFromReader loads the points in data from a reader, the format is detected
+// from the contents and is GPX when it isn't recognized
 func NewGPXDatasetFromReader(reader io.Reader) (GPXDataset, error) {
 	ds := GPXDataset{}
 
-	b, err := io.ReadAll(reader)
-	if err != nil {
-		return GPXDataset{}, fmt.Errorf("failed to data from reader: %w", err)
+	f, r := sniffFormat(reader)
+	if f == nil {
+		f = &formats[0]
 	}
 
-	rawData, err := gpx.ParseBytes(b)
+	rawData, err := f.parse(r, ReadOptions{})
 	if err != nil {
-		return GPXDataset{}, fmt.Errorf("failed to parse gpx data from file: %w", err)
+		return GPXDataset{}, fmt.Errorf("failed to parse %s data from reader: %w", f.name, err)
 	}
 
 	ds.data = append(ds.data, rawData)
+	ds.buildIndex()
 
 	return ds, nil
 }
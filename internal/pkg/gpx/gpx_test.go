@@ -1,7 +1,10 @@
 package gpx
 
 import (
+	"errors"
 	"os"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -97,9 +100,11 @@ func TestInRange(t *testing.T) {
 func TestAtTime(t *testing.T) {
 	testCases := map[string]struct {
 		File          string
+		MaxGap        time.Duration
 		Time          time.Time
 		ExpectedPoint *gpx.GPXPoint
 		ExpectedError *string
+		ExpectNoFix   bool
 	}{
 		"run example with match": {
 			File: "fixtures/run.gpx",
@@ -121,6 +126,30 @@ func TestAtTime(t *testing.T) {
 			File:          "fixtures/run.gpx",
 			Time:          time.Date(2022, time.August, 4, 8, 53, 0, 0, time.UTC),
 			ExpectedError: strPtr("out of range of loaded files"),
+			ExpectNoFix:   true,
+		},
+		"run example with no match within max gap returns error": {
+			File:          "fixtures/run.gpx",
+			MaxGap:        10 * time.Minute,
+			Time:          time.Date(2022, time.August, 3, 9, 3, 0, 0, time.UTC),
+			ExpectedError: strPtr("out of range of loaded files"),
+			ExpectNoFix:   true,
+		},
+		"run example with match within max gap after range": {
+			File:   "fixtures/run.gpx",
+			MaxGap: 10 * time.Minute,
+			Time:   time.Date(2022, time.August, 3, 9, 2, 0, 0, time.UTC),
+			ExpectedPoint: &gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 51.5673220, Longitude: -0.1383400},
+				Timestamp: time.Date(2022, time.August, 3, 8, 52, 9, 0, time.UTC),
+			},
+		},
+		"run example with gap between points larger than max gap returns error": {
+			File:          "fixtures/run.gpx",
+			MaxGap:        time.Second,
+			Time:          time.Date(2022, time.August, 3, 8, 10, 0, 0, time.UTC),
+			ExpectedError: strPtr("no fix"),
+			ExpectNoFix:   true,
 		},
 	}
 
@@ -128,11 +157,15 @@ func TestAtTime(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			gpxDataset, err := NewGPXDatasetFromDisk(testCase.File)
 			require.NoError(t, err)
+			gpxDataset.MaxGap = testCase.MaxGap
 
 			point, err := gpxDataset.AtTime(testCase.Time)
 
 			if testCase.ExpectedError != nil {
 				require.ErrorContains(t, err, *testCase.ExpectedError)
+
+				var noFixErr *NoFixError
+				assert.Equal(t, testCase.ExpectNoFix, errors.As(err, &noFixErr))
 			} else {
 				require.NoError(t, err)
 			}
@@ -153,3 +186,278 @@ func TestAtTime(t *testing.T) {
 func strPtr(str string) *string {
 	return &str
 }
+
+func TestMatch(t *testing.T) {
+	testCases := map[string]struct {
+		File              string
+		Interpolation     Interpolation
+		Time              time.Time
+		ExpectedLatitude  float64
+		ExpectedLongitude float64
+		ExpectedElevation float64
+		ExpectedMethod    Interpolation
+		ExpectedNearest   time.Time
+	}{
+		"nearest between points": {
+			File:              "fixtures/run.gpx",
+			Interpolation:     InterpolationNearest,
+			Time:              time.Date(2022, time.August, 3, 8, 10, 0, 0, time.UTC),
+			ExpectedLatitude:  51.5671980,
+			ExpectedLongitude: -0.1413280,
+			ExpectedElevation: 90.2,
+			ExpectedMethod:    InterpolationNearest,
+			ExpectedNearest:   time.Date(2022, time.August, 3, 8, 9, 57, 0, time.UTC),
+		},
+		"linear between points": {
+			File:              "fixtures/run.gpx",
+			Interpolation:     InterpolationLinear,
+			Time:              time.Date(2022, time.August, 3, 8, 10, 0, 0, time.UTC),
+			ExpectedLatitude:  51.5672810,
+			ExpectedLongitude: -0.1414155,
+			ExpectedElevation: 90.7,
+			ExpectedMethod:    InterpolationLinear,
+			ExpectedNearest:   time.Date(2022, time.August, 3, 8, 9, 57, 0, time.UTC),
+		},
+		"great circle between points": {
+			File:              "fixtures/run.gpx",
+			Interpolation:     InterpolationGreatCircle,
+			Time:              time.Date(2022, time.August, 3, 8, 10, 1, 0, time.UTC),
+			ExpectedLatitude:  51.5673087,
+			ExpectedLongitude: -0.1414447,
+			ExpectedElevation: 90.8667,
+			ExpectedMethod:    InterpolationGreatCircle,
+			ExpectedNearest:   time.Date(2022, time.August, 3, 8, 10, 3, 0, time.UTC),
+		},
+		"linear on a recorded point": {
+			File:              "fixtures/run.gpx",
+			Interpolation:     InterpolationLinear,
+			Time:              time.Date(2022, time.August, 3, 8, 10, 3, 0, time.UTC),
+			ExpectedLatitude:  51.5673640,
+			ExpectedLongitude: -0.1415030,
+			ExpectedElevation: 91.2,
+			ExpectedMethod:    InterpolationNearest,
+			ExpectedNearest:   time.Date(2022, time.August, 3, 8, 10, 3, 0, time.UTC),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDisk(testCase.File)
+			require.NoError(t, err)
+			gpxDataset.Interpolation = testCase.Interpolation
+
+			match, err := gpxDataset.Match(testCase.Time)
+			require.NoError(t, err)
+
+			assert.InDelta(t, testCase.ExpectedLatitude, match.Point.Latitude, 0.0000001)
+			assert.InDelta(t, testCase.ExpectedLongitude, match.Point.Longitude, 0.0000001)
+			assert.InDelta(t, testCase.ExpectedElevation, match.Point.Elevation.Value(), 0.0001)
+			assert.Equal(t, testCase.ExpectedMethod, match.Method)
+			assert.Equal(t, testCase.ExpectedNearest, match.Nearest.Timestamp)
+		})
+	}
+}
+
+func TestAllPoints(t *testing.T) {
+	testCases := map[string]struct {
+		Files              []string
+		ExpectedPointCount int
+	}{
+		"single file": {
+			Files:              []string{"fixtures/run.gpx"},
+			ExpectedPointCount: 848,
+		},
+		"duplicate files are de-duplicated": {
+			Files:              []string{"fixtures/run.gpx", "fixtures/run.gpx"},
+			ExpectedPointCount: 848,
+		},
+		"multiple files": {
+			Files:              []string{"fixtures/run_nida.gpx", "fixtures/run.gpx"},
+			ExpectedPointCount: 848 + 583,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDisk(testCase.Files...)
+			require.NoError(t, err)
+
+			points := gpxDataset.AllPoints()
+			assert.Len(t, points, testCase.ExpectedPointCount)
+
+			for i := 1; i < len(points); i++ {
+				require.True(t, points[i-1].Timestamp.Before(points[i].Timestamp))
+			}
+		})
+	}
+}
+
+func TestMatchConcurrent(t *testing.T) {
+	gpxDataset, err := NewGPXDatasetFromDisk("fixtures/run.gpx", "fixtures/run_nida.gpx")
+	require.NoError(t, err)
+	gpxDataset.Interpolation = InterpolationLinear
+
+	var times []time.Time
+	for _, p := range gpxDataset.AllPoints() {
+		times = append(times, p.Timestamp.Add(500*time.Millisecond))
+	}
+
+	expected := make([]Match, len(times))
+	expectedErrs := make([]error, len(times))
+	for i, tm := range times {
+		expected[i], expectedErrs[i] = gpxDataset.Match(tm)
+	}
+
+	// run with -race to check that lookups don't modify the dataset
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range times {
+				m, err := gpxDataset.Match(times[i])
+				assert.Equal(t, expectedErrs[i], err)
+				assert.Equal(t, expected[i], m)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkAtTime(b *testing.B) {
+	gpxDataset, times := loadBenchmarkDataset(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := gpxDataset.AtTime(times[i%len(times)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAtTimeLinearScan measures the approach used before the dataset was
+// indexed, where all points were collected, sorted and scanned for every lookup
+func BenchmarkAtTimeLinearScan(b *testing.B) {
+	gpxDataset, times := loadBenchmarkDataset(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearScanAtTime(&gpxDataset, times[i%len(times)])
+	}
+}
+
+func loadBenchmarkDataset(b *testing.B) (GPXDataset, []time.Time) {
+	gpxDataset, err := NewGPXDatasetFromDisk("fixtures/run.gpx", "fixtures/run_nida.gpx")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var times []time.Time
+	for _, p := range gpxDataset.AllPoints() {
+		times = append(times, p.Timestamp.Add(500*time.Millisecond))
+	}
+
+	return gpxDataset, times
+}
+
+func linearScanAtTime(g *GPXDataset, t time.Time) gpx.GPXPoint {
+	var allPoints []gpx.GPXPoint
+	for _, d := range g.data {
+		for _, track := range d.Tracks {
+			for _, segment := range track.Segments {
+				allPoints = append(allPoints, segment.Points...)
+			}
+		}
+	}
+	sort.Slice(allPoints, func(i, j int) bool { return allPoints[i].Timestamp.Before(allPoints[j].Timestamp) })
+
+	var closestPoint gpx.GPXPoint
+	minDiff := time.Hour * 24 * 365
+	for _, point := range allPoints {
+		diff := t.Sub(point.Timestamp)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < minDiff {
+			minDiff = diff
+			closestPoint = point
+		}
+	}
+
+	return closestPoint
+}
+
+func TestMatchSegments(t *testing.T) {
+	testCases := map[string]struct {
+		StationaryRadius  float64
+		MaxGap            time.Duration
+		Time              time.Time
+		ExpectedLatitude  float64
+		ExpectedLongitude float64
+		ExpectNoFix       bool
+		// ExpectGapExceeded is set when there's no fix because the time is further
+		// than the max gap from the point which would be used
+		ExpectGapExceeded bool
+	}{
+		"inside a segment is interpolated": {
+			Time:              time.Date(2022, time.August, 3, 10, 0, 10, 0, time.UTC),
+			ExpectedLatitude:  51.50010,
+			ExpectedLongitude: -0.12000,
+		},
+		"inside a pause has no fix": {
+			Time:        time.Date(2022, time.August, 3, 10, 15, 0, 0, time.UTC),
+			ExpectNoFix: true,
+		},
+		"inside a pause shortly before the next segment has no fix": {
+			Time:        time.Date(2022, time.August, 3, 10, 29, 59, 0, time.UTC),
+			ExpectNoFix: true,
+		},
+		"inside a pause when stationary uses last point": {
+			StationaryRadius:  10,
+			Time:              time.Date(2022, time.August, 3, 10, 15, 0, 0, time.UTC),
+			ExpectedLatitude:  51.50020,
+			ExpectedLongitude: -0.12000,
+		},
+		"inside a pause longer than the max gap when stationary has no fix": {
+			StationaryRadius:  10,
+			MaxGap:            10 * time.Minute,
+			Time:              time.Date(2022, time.August, 3, 10, 15, 0, 0, time.UTC),
+			ExpectNoFix:       true,
+			ExpectGapExceeded: true,
+		},
+		"between tracks when not stationary has no fix": {
+			StationaryRadius: 10,
+			Time:             time.Date(2022, time.August, 3, 11, 0, 0, 0, time.UTC),
+			ExpectNoFix:      true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDisk("fixtures/paused.gpx")
+			require.NoError(t, err)
+			gpxDataset.Interpolation = InterpolationLinear
+			gpxDataset.StationaryRadius = testCase.StationaryRadius
+			gpxDataset.MaxGap = testCase.MaxGap
+
+			match, err := gpxDataset.Match(testCase.Time)
+			if testCase.ExpectNoFix {
+				var noFixErr *NoFixError
+				require.ErrorAs(t, err, &noFixErr)
+				assert.Equal(t, !testCase.ExpectGapExceeded, noFixErr.BetweenSegments)
+				if testCase.ExpectGapExceeded {
+					assert.Greater(t, noFixErr.Gap, noFixErr.MaxGap)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			assert.InDelta(t, testCase.ExpectedLatitude, match.Point.Latitude, 0.0000001)
+			assert.InDelta(t, testCase.ExpectedLongitude, match.Point.Longitude, 0.0000001)
+			if match.Method == InterpolationNearest {
+				assert.Equal(t, match.Point, match.Nearest)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package gpx
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// Interpolation is the method used to determine a position between two recorded points
+type Interpolation string
+
+const (
+	// InterpolationNearest uses the recorded point closest in time
+	InterpolationNearest Interpolation = "nearest"
+	// InterpolationLinear interpolates latitude, longitude and elevation linearly
+	InterpolationLinear Interpolation = "linear"
+	// InterpolationGreatCircle interpolates along the great circle between the points,
+	// elevation is interpolated linearly
+	InterpolationGreatCircle Interpolation = "great-circle"
+)
+
+// ParseInterpolation returns the Interpolation for a given name, an empty name is
+// treated as InterpolationNearest
+func ParseInterpolation(name string) (Interpolation, error) {
+	switch Interpolation(name) {
+	case "", InterpolationNearest:
+		return InterpolationNearest, nil
+	case InterpolationLinear:
+		return InterpolationLinear, nil
+	case InterpolationGreatCircle:
+		return InterpolationGreatCircle, nil
+	}
+
+	return "", fmt.Errorf("unknown interpolation method %q", name)
+}
+
+// interpolate returns a point at time t between the points a and b using the
+// supplied method. t is expected to be between the timestamps of a and b.
+func interpolate(method Interpolation, a, b gpx.GPXPoint, t time.Time) gpx.GPXPoint {
+	span := b.Timestamp.Sub(a.Timestamp)
+	if span <= 0 {
+		return a
+	}
+	fraction := float64(t.Sub(a.Timestamp)) / float64(span)
+
+	var latitude, longitude float64
+	switch method {
+	case InterpolationGreatCircle:
+		latitude, longitude = interpolateGreatCircle(a.Point, b.Point, fraction)
+	default:
+		latitude, longitude = interpolateLinear(a.Point, b.Point, fraction)
+	}
+
+	p := gpx.GPXPoint{
+		Point: gpx.Point{
+			Latitude:  latitude,
+			Longitude: longitude,
+		},
+		Timestamp: t,
+	}
+
+	// elevation is only interpolated when both points have a value, otherwise
+	// the value of the point closest in time is used
+	switch {
+	case a.Elevation.NotNull() && b.Elevation.NotNull():
+		p.Elevation = *gpx.NewNullableFloat64(a.Elevation.Value() + (b.Elevation.Value()-a.Elevation.Value())*fraction)
+	case fraction < 0.5:
+		p.Elevation = a.Elevation
+	default:
+		p.Elevation = b.Elevation
+	}
+
+	return p
+}
+
+func interpolateLinear(a, b gpx.Point, fraction float64) (float64, float64) {
+	latitude := a.Latitude + (b.Latitude-a.Latitude)*fraction
+
+	// take the short way around when crossing the antimeridian
+	deltaLongitude := b.Longitude - a.Longitude
+	if deltaLongitude > 180 {
+		deltaLongitude -= 360
+	} else if deltaLongitude < -180 {
+		deltaLongitude += 360
+	}
+
+	return latitude, normalizeLongitude(a.Longitude + deltaLongitude*fraction)
+}
+
+func interpolateGreatCircle(a, b gpx.Point, fraction float64) (float64, float64) {
+	lat1, lon1 := toRadians(a.Latitude), toRadians(a.Longitude)
+	lat2, lon2 := toRadians(b.Latitude), toRadians(b.Longitude)
+
+	// angular distance between the points, rounding can take the haversine just over 1
+	d := 2 * math.Asin(math.Sqrt(math.Min(1,
+		math.Pow(math.Sin((lat2-lat1)/2), 2)+
+			math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin((lon2-lon1)/2), 2),
+	)))
+
+	// the great circle isn't defined for identical or antipodal points, and the
+	// weights below divide by sin(d)
+	if math.Sin(d) < 1e-9 {
+		return interpolateLinear(a, b, fraction)
+	}
+
+	x1, y1, z1 := math.Cos(lat1)*math.Cos(lon1), math.Cos(lat1)*math.Sin(lon1), math.Sin(lat1)
+	x2, y2, z2 := math.Cos(lat2)*math.Cos(lon2), math.Cos(lat2)*math.Sin(lon2), math.Sin(lat2)
+
+	wa := math.Sin((1-fraction)*d) / math.Sin(d)
+	wb := math.Sin(fraction*d) / math.Sin(d)
+
+	x := wa*x1 + wb*x2
+	y := wa*y1 + wb*y2
+	z := wa*z1 + wb*z2
+
+	return toDegrees(math.Atan2(z, math.Sqrt(x*x+y*y))), toDegrees(math.Atan2(y, x))
+}
+
+func normalizeLongitude(longitude float64) float64 {
+	for longitude > 180 {
+		longitude -= 360
+	}
+	for longitude < -180 {
+		longitude += 360
+	}
+	return longitude
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func toDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
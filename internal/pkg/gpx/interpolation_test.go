@@ -0,0 +1,174 @@
+package gpx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestParseInterpolation(t *testing.T) {
+	testCases := map[string]struct {
+		Name          string
+		Expected      Interpolation
+		ExpectedError bool
+	}{
+		"empty":        {Name: "", Expected: InterpolationNearest},
+		"nearest":      {Name: "nearest", Expected: InterpolationNearest},
+		"linear":       {Name: "linear", Expected: InterpolationLinear},
+		"great-circle": {Name: "great-circle", Expected: InterpolationGreatCircle},
+		"unknown":      {Name: "cubic", ExpectedError: true},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := ParseInterpolation(testCase.Name)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Expected, result)
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	start := time.Date(2022, time.August, 3, 8, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		Method            Interpolation
+		A, B              gpx.GPXPoint
+		Time              time.Time
+		ExpectedLatitude  float64
+		ExpectedLongitude float64
+		ExpectedElevation *float64
+	}{
+		"linear halfway": {
+			Method: InterpolationLinear,
+			A: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 51, Longitude: -1, Elevation: *gpx.NewNullableFloat64(10)},
+				Timestamp: start,
+			},
+			B: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 52, Longitude: 1, Elevation: *gpx.NewNullableFloat64(20)},
+				Timestamp: start.Add(time.Minute),
+			},
+			Time:              start.Add(30 * time.Second),
+			ExpectedLatitude:  51.5,
+			ExpectedLongitude: 0,
+			ExpectedElevation: floatPtr(15),
+		},
+		"linear across the antimeridian": {
+			Method: InterpolationLinear,
+			A: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 0, Longitude: 179},
+				Timestamp: start,
+			},
+			B: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 0, Longitude: -179},
+				Timestamp: start.Add(time.Minute),
+			},
+			Time:              start.Add(45 * time.Second),
+			ExpectedLatitude:  0,
+			ExpectedLongitude: -179.5,
+		},
+		"linear with missing elevation uses nearest": {
+			Method: InterpolationLinear,
+			A: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 0, Longitude: 0, Elevation: *gpx.NewNullableFloat64(10)},
+				Timestamp: start,
+			},
+			B: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 0, Longitude: 1},
+				Timestamp: start.Add(time.Minute),
+			},
+			Time:              start.Add(15 * time.Second),
+			ExpectedLatitude:  0,
+			ExpectedLongitude: 0.25,
+			ExpectedElevation: floatPtr(10),
+		},
+		"great circle on the equator": {
+			Method: InterpolationGreatCircle,
+			A: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 0, Longitude: 10},
+				Timestamp: start,
+			},
+			B: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 0, Longitude: 20},
+				Timestamp: start.Add(time.Minute),
+			},
+			Time:              start.Add(30 * time.Second),
+			ExpectedLatitude:  0,
+			ExpectedLongitude: 15,
+		},
+		"great circle bends towards the pole": {
+			Method: InterpolationGreatCircle,
+			A: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 60, Longitude: -90},
+				Timestamp: start,
+			},
+			B: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 60, Longitude: 90},
+				Timestamp: start.Add(time.Minute),
+			},
+			Time:              start.Add(30 * time.Second),
+			ExpectedLatitude:  90,
+			ExpectedLongitude: 0,
+		},
+		"great circle between antipodal points is linear": {
+			Method: InterpolationGreatCircle,
+			A: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 10, Longitude: 20},
+				Timestamp: start,
+			},
+			B: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: -10, Longitude: -160},
+				Timestamp: start.Add(time.Minute),
+			},
+			Time:              start.Add(30 * time.Second),
+			ExpectedLatitude:  0,
+			ExpectedLongitude: -70,
+		},
+		"great circle between identical points": {
+			Method: InterpolationGreatCircle,
+			A: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 51.5, Longitude: -0.12},
+				Timestamp: start,
+			},
+			B: gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: 51.5, Longitude: -0.12},
+				Timestamp: start.Add(time.Minute),
+			},
+			Time:              start.Add(30 * time.Second),
+			ExpectedLatitude:  51.5,
+			ExpectedLongitude: -0.12,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := interpolate(testCase.Method, testCase.A, testCase.B, testCase.Time)
+
+			assert.InDelta(t, testCase.ExpectedLatitude, p.Latitude, 0.000001)
+			if testCase.ExpectedLatitude != 90 {
+				assert.InDelta(t, testCase.ExpectedLongitude, p.Longitude, 0.000001)
+			}
+			assert.Equal(t, testCase.Time, p.Timestamp)
+
+			if testCase.ExpectedElevation != nil {
+				require.True(t, p.Elevation.NotNull())
+				assert.InDelta(t, *testCase.ExpectedElevation, p.Elevation.Value(), 0.000001)
+			} else {
+				assert.True(t, p.Elevation.Null())
+			}
+		})
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
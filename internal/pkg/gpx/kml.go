@@ -0,0 +1,181 @@
+package gpx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// parseKML reads the gx:Track elements in KML data, each track becomes a GPX track
+// with a single segment. Other KML elements are ignored as they're not timed.
+func parseKML(r io.Reader) (*gpx.GPX, error) {
+	data := &gpx.GPX{}
+
+	var (
+		inTrack bool
+		name    string
+		whens   []string
+		coords  []string
+	)
+
+	d := xml.NewDecoder(r)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read KML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Placemark":
+				name = ""
+			case "name":
+				if inTrack {
+					continue
+				}
+				var n string
+				err = d.DecodeElement(&n, &t)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read name: %w", err)
+				}
+				name = strings.TrimSpace(n)
+			case "Track":
+				inTrack = true
+				whens, coords = nil, nil
+			case "when", "coord":
+				if !inTrack {
+					continue
+				}
+				var value string
+				err = d.DecodeElement(&value, &t)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read %s: %w", t.Name.Local, err)
+				}
+				if t.Name.Local == "when" {
+					whens = append(whens, strings.TrimSpace(value))
+				} else {
+					coords = append(coords, strings.TrimSpace(value))
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local != "Track" || !inTrack {
+				continue
+			}
+			inTrack = false
+
+			track, err := kmlTrack(name, whens, coords)
+			if err != nil {
+				return nil, err
+			}
+			data.Tracks = append(data.Tracks, track)
+		}
+	}
+
+	return data, nil
+}
+
+// kmlTrack returns a track from the when and gx:coord values of a gx:Track, which are
+// paired by their order
+func kmlTrack(name string, whens, coords []string) (gpx.GPXTrack, error) {
+	if len(whens) != len(coords) {
+		return gpx.GPXTrack{}, fmt.Errorf("track has %d when elements but %d coord elements", len(whens), len(coords))
+	}
+
+	var segment gpx.GPXTrackSegment
+	for i := range whens {
+		// coords may be empty when the position wasn't known at the time
+		if coords[i] == "" {
+			continue
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, whens[i])
+		if err != nil {
+			return gpx.GPXTrack{}, fmt.Errorf("failed to parse when %q: %w", whens[i], err)
+		}
+
+		point, err := parseKMLCoord(coords[i])
+		if err != nil {
+			return gpx.GPXTrack{}, err
+		}
+
+		segment.Points = append(segment.Points, gpx.GPXPoint{Point: point, Timestamp: timestamp.UTC()})
+	}
+
+	return gpx.GPXTrack{Name: name, Segments: []gpx.GPXTrackSegment{segment}}, nil
+}
+
+// parseKMLCoord parses a gx:coord value of "longitude latitude [altitude]"
+func parseKMLCoord(coord string) (gpx.Point, error) {
+	fields := strings.Fields(coord)
+	if len(fields) < 2 || len(fields) > 3 {
+		return gpx.Point{}, fmt.Errorf("coord %q was not in the form longitude latitude altitude", coord)
+	}
+
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return gpx.Point{}, fmt.Errorf("failed to parse coord %q: %w", coord, err)
+		}
+		values[i] = v
+	}
+
+	p := gpx.Point{Latitude: values[1], Longitude: values[0]}
+	if len(values) == 3 {
+		p.Elevation = *gpx.NewNullableFloat64(values[2])
+	}
+
+	return p, nil
+}
+
+// errNoKMLDocument is returned when a KMZ archive, or a zip sniffed as one, has no KML
+// document
+var errNoKMLDocument = errors.New("KMZ archive has no KML document")
+
+// parseKMZ reads the KML document in a KMZ archive, which is doc.kml or otherwise the
+// first .kml file in the archive
+func parseKMZ(r io.Reader) (*gpx.GPX, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open KMZ archive: %w", err)
+	}
+
+	var doc *zip.File
+	for _, f := range archive.File {
+		if !strings.EqualFold(path.Ext(f.Name), ".kml") {
+			continue
+		}
+		if doc == nil || f.Name == "doc.kml" {
+			doc = f
+		}
+	}
+	if doc == nil {
+		return nil, errNoKMLDocument
+	}
+
+	rc, err := doc.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in KMZ archive: %w", doc.Name, err)
+	}
+	defer rc.Close()
+
+	return parseKML(rc)
+}
@@ -0,0 +1,218 @@
+package gpx
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestNewGPXDatasetFromDiskKML(t *testing.T) {
+	testCases := map[string]struct {
+		Paths                  []string
+		ExpectedPointCount     int
+		ExpectedFirstPointTime time.Time
+		ExpectedFirstPoint     gpx.Point
+		ExpectedLastPointTime  time.Time
+		ExpectedLastPoint      gpx.Point
+		ExpectedError          string
+	}{
+		"kml": {
+			Paths:                  []string{"fixtures/history.kml"},
+			ExpectedPointCount:     5,
+			ExpectedFirstPointTime: time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC),
+			ExpectedFirstPoint: gpx.Point{
+				Latitude:  51.5,
+				Longitude: -0.12,
+				Elevation: *gpx.NewNullableFloat64(10),
+			},
+			ExpectedLastPointTime: time.Date(2022, time.August, 3, 9, 11, 0, 0, time.UTC),
+			ExpectedLastPoint: gpx.Point{
+				Latitude:  51.51,
+				Longitude: -0.09,
+				Elevation: *gpx.NewNullableFloat64(20),
+			},
+		},
+		"kmz": {
+			Paths:                  []string{"fixtures/history.kmz"},
+			ExpectedPointCount:     5,
+			ExpectedFirstPointTime: time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC),
+			ExpectedFirstPoint: gpx.Point{
+				Latitude:  51.5,
+				Longitude: -0.12,
+				Elevation: *gpx.NewNullableFloat64(10),
+			},
+			ExpectedLastPointTime: time.Date(2022, time.August, 3, 9, 11, 0, 0, time.UTC),
+			ExpectedLastPoint: gpx.Point{
+				Latitude:  51.51,
+				Longitude: -0.09,
+				Elevation: *gpx.NewNullableFloat64(20),
+			},
+		},
+		"mixed directory": {
+			Paths:                  []string{"fixtures/mixed"},
+			ExpectedPointCount:     11,
+			ExpectedFirstPointTime: time.Date(2022, time.August, 3, 10, 0, 0, 0, time.UTC),
+			ExpectedFirstPoint: gpx.Point{
+				Latitude:  51.5,
+				Longitude: -0.12,
+				Elevation: *gpx.NewNullableFloat64(10),
+			},
+			ExpectedLastPointTime: time.Date(2022, time.August, 4, 9, 11, 0, 0, time.UTC),
+			ExpectedLastPoint: gpx.Point{
+				Latitude:  51.51,
+				Longitude: -0.09,
+				Elevation: *gpx.NewNullableFloat64(20),
+			},
+		},
+		"unsupported file": {
+			Paths:         []string{"fixtures/mixed/README.txt"},
+			ExpectedError: "format of fixtures/mixed/README.txt is not supported",
+		},
+		"zip without a KML document": {
+			Paths:         []string{"fixtures/mixed/archive.zip"},
+			ExpectedError: "format of fixtures/mixed/archive.zip is not supported",
+		},
+		"kmz without a KML document": {
+			Paths:         []string{"fixtures/empty.kmz"},
+			ExpectedError: "failed to parse KMZ data from file fixtures/empty.kmz: KMZ archive has no KML document",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDisk(testCase.Paths...)
+			if testCase.ExpectedError != "" {
+				assert.EqualError(t, err, testCase.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			points := gpxDataset.AllPoints()
+			require.Len(t, points, testCase.ExpectedPointCount)
+
+			assert.Equal(t, testCase.ExpectedFirstPointTime, points[0].Timestamp)
+			assert.Equal(t, testCase.ExpectedFirstPoint, points[0].Point)
+			assert.Equal(t, testCase.ExpectedLastPointTime, points[len(points)-1].Timestamp)
+			assert.Equal(t, testCase.ExpectedLastPoint, points[len(points)-1].Point)
+		})
+	}
+}
+
+func TestParseKML(t *testing.T) {
+	file, err := os.Open("fixtures/history.kml")
+	require.NoError(t, err)
+	defer file.Close()
+
+	data, err := parseKML(file)
+	require.NoError(t, err)
+
+	require.Len(t, data.Tracks, 2)
+	assert.Equal(t, "Walk to the station", data.Tracks[0].Name)
+	assert.Equal(t, "Train", data.Tracks[1].Name)
+
+	// the point with an empty coord is skipped
+	points := data.Tracks[0].Segments[0].Points
+	require.Len(t, points, 3)
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 1, 30, 0, time.UTC), points[2].Timestamp)
+	assert.False(t, points[2].Elevation.NotNull())
+}
+
+func TestParseKMLErrors(t *testing.T) {
+	testCases := map[string]struct {
+		Track         string
+		ExpectedError string
+	}{
+		"more when than coord elements": {
+			Track: `<when>2022-08-03T09:00:00Z</when>
+				<when>2022-08-03T09:00:30Z</when>
+				<gx:coord>-0.12 51.5 10</gx:coord>`,
+			ExpectedError: "track has 2 when elements but 1 coord elements",
+		},
+		"invalid when": {
+			Track: `<when>yesterday</when>
+				<gx:coord>-0.12 51.5 10</gx:coord>`,
+			ExpectedError: `failed to parse when "yesterday"`,
+		},
+		"invalid coord": {
+			Track: `<when>2022-08-03T09:00:00Z</when>
+				<gx:coord>51.5</gx:coord>`,
+			ExpectedError: `coord "51.5" was not in the form longitude latitude altitude`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			kml := `<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+				<Placemark><gx:Track>` + testCase.Track + `</gx:Track></Placemark>
+			</kml>`
+
+			_, err := parseKML(strings.NewReader(kml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.ExpectedError)
+		})
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	testCases := map[string]struct {
+		File         string
+		ExpectedName string
+	}{
+		"gpx": {
+			File:         "fixtures/mixed/paused.gpx",
+			ExpectedName: "GPX",
+		},
+		"kml without an extension": {
+			File:         "fixtures/mixed/history-export",
+			ExpectedName: "KML",
+		},
+		"kmz": {
+			File:         "fixtures/history.kmz",
+			ExpectedName: "KMZ",
+		},
+		"fit": {
+			File:         "fixtures/ride.fit",
+			ExpectedName: "FIT",
+		},
+		"tcx": {
+			File:         "fixtures/ride.tcx",
+			ExpectedName: "TCX",
+		},
+		"nmea": {
+			File:         "fixtures/logger.nmea",
+			ExpectedName: "NMEA",
+		},
+		"geojson": {
+			File:         "fixtures/track.geojson",
+			ExpectedName: "GeoJSON",
+		},
+		// CSV is only detected by its extension
+		"csv": {
+			File: "fixtures/points.csv",
+		},
+		"text": {
+			File: "fixtures/mixed/README.txt",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open(testCase.File)
+			require.NoError(t, err)
+			defer file.Close()
+
+			f, _ := sniffFormat(file)
+			if testCase.ExpectedName == "" {
+				assert.Nil(t, f)
+				return
+			}
+			require.NotNil(t, f)
+			assert.Equal(t, testCase.ExpectedName, f.name)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package gpx
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ErrNoMotion is returned by Motion when there aren't recorded points on both sides of
+// the time in the same track segment
+var ErrNoMotion = errors.New("no recorded points either side of the time in the same track segment")
+
+// Motion is the direction and speed of travel at a point in time
+type Motion struct {
+	// Course is the direction of travel in degrees clockwise from true north
+	Course float64
+	// Speed is in meters per second
+	Speed float64
+}
+
+// Motion returns the direction and speed of travel at a time, from the recorded points
+// either side of it. When the time is that of a recorded point, the points before and
+// after it are used. The points must be in the same track segment and no more than
+// MaxGap apart, ErrNoMotion is returned otherwise.
+func (g *GPXDataset) Motion(t time.Time) (Motion, error) {
+	maxGap := g.MaxGap
+	if maxGap <= 0 {
+		maxGap = DefaultMaxGap
+	}
+
+	allPoints := g.points
+
+	next := sort.Search(len(allPoints), func(i int) bool { return !allPoints[i].Timestamp.Before(t) })
+	if next == len(allPoints) {
+		return Motion{}, ErrNoMotion
+	}
+	previous := next - 1
+	if allPoints[next].Timestamp.Equal(t) {
+		// use the neighbours of the recorded point, or the point itself at the ends
+		// of a segment
+		current := next
+		if previous < 0 || g.segments[previous] != g.segments[current] {
+			previous = current
+		}
+		if next+1 < len(allPoints) && g.segments[next+1] == g.segments[current] {
+			next++
+		}
+	}
+	if previous < 0 || previous == next || g.segments[previous] != g.segments[next] {
+		return Motion{}, ErrNoMotion
+	}
+
+	a, b := allPoints[previous], allPoints[next]
+
+	span := b.Timestamp.Sub(a.Timestamp)
+	if span <= 0 || span > maxGap {
+		return Motion{}, ErrNoMotion
+	}
+
+	distance := gpx.HaversineDistance(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+
+	return Motion{
+		Course: bearing(a.Point, b.Point),
+		Speed:  distance / span.Seconds(),
+	}, nil
+}
+
+// bearing returns the initial bearing from a to b in degrees clockwise from true north
+func bearing(a, b gpx.Point) float64 {
+	lat1, lon1 := toRadians(a.Latitude), toRadians(a.Longitude)
+	lat2, lon2 := toRadians(b.Latitude), toRadians(b.Longitude)
+
+	y := math.Sin(lon2-lon1) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(lon2-lon1)
+
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
@@ -0,0 +1,105 @@
+package gpx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestMotion(t *testing.T) {
+	testCases := map[string]struct {
+		Time           time.Time
+		MaxGap         time.Duration
+		ExpectedCourse float64
+		ExpectedSpeed  float64
+		ExpectNoMotion bool
+	}{
+		"between points": {
+			Time:           time.Date(2022, time.August, 3, 10, 0, 10, 0, time.UTC),
+			ExpectedCourse: 0,
+			ExpectedSpeed:  1.112,
+		},
+		"at the first point of a segment": {
+			Time:           time.Date(2022, time.August, 3, 10, 30, 0, 0, time.UTC),
+			ExpectedCourse: 0,
+			ExpectedSpeed:  1.112,
+		},
+		"at the last point of a segment": {
+			Time:           time.Date(2022, time.August, 3, 10, 0, 20, 0, time.UTC),
+			ExpectedCourse: 0,
+			ExpectedSpeed:  1.112,
+		},
+		"between segments": {
+			Time:           time.Date(2022, time.August, 3, 10, 15, 0, 0, time.UTC),
+			ExpectNoMotion: true,
+		},
+		"before the first point": {
+			Time:           time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC),
+			ExpectNoMotion: true,
+		},
+		"after the last point": {
+			Time:           time.Date(2022, time.August, 3, 13, 0, 0, 0, time.UTC),
+			ExpectNoMotion: true,
+		},
+		"points further apart than the max gap": {
+			Time:           time.Date(2022, time.August, 3, 10, 0, 10, 0, time.UTC),
+			MaxGap:         10 * time.Second,
+			ExpectNoMotion: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDisk("fixtures/paused.gpx")
+			require.NoError(t, err)
+			gpxDataset.MaxGap = testCase.MaxGap
+
+			motion, err := gpxDataset.Motion(testCase.Time)
+			if testCase.ExpectNoMotion {
+				assert.ErrorIs(t, err, ErrNoMotion)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.InDelta(t, testCase.ExpectedCourse, motion.Course, 0.01)
+			assert.InDelta(t, testCase.ExpectedSpeed, motion.Speed, 0.001)
+		})
+	}
+}
+
+func TestBearing(t *testing.T) {
+	testCases := map[string]struct {
+		A, B     gpx.Point
+		Expected float64
+	}{
+		"north": {
+			A:        gpx.Point{Latitude: 51, Longitude: 0},
+			B:        gpx.Point{Latitude: 52, Longitude: 0},
+			Expected: 0,
+		},
+		"east": {
+			A:        gpx.Point{Latitude: 0, Longitude: 10},
+			B:        gpx.Point{Latitude: 0, Longitude: 11},
+			Expected: 90,
+		},
+		"south": {
+			A:        gpx.Point{Latitude: 52, Longitude: 0},
+			B:        gpx.Point{Latitude: 51, Longitude: 0},
+			Expected: 180,
+		},
+		"west across the antimeridian": {
+			A:        gpx.Point{Latitude: 0, Longitude: -179.5},
+			B:        gpx.Point{Latitude: 0, Longitude: 179.5},
+			Expected: 270,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.InDelta(t, testCase.Expected, bearing(testCase.A, testCase.B), 0.01)
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package gpx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// nmeaEpoch holds the sentences for a single time of day, loggers write an RMC and a
+// GGA sentence for each fix
+type nmeaEpoch struct {
+	timeOfDay string
+	rmc       *nmeaRMC
+	gga       *nmeaGGA
+}
+
+// nmeaRMC is the position, date and time from an RMC sentence
+type nmeaRMC struct {
+	valid     bool
+	point     gpx.Point
+	timestamp time.Time
+}
+
+// nmeaGGA is the position, fix quality and altitude from a GGA sentence
+type nmeaGGA struct {
+	quality    int
+	point      gpx.Point
+	timeOfDay  time.Duration
+	satellites *int
+	hdop       *float64
+	altitude   *float64
+}
+
+// parseNMEA reads the RMC and GGA sentences of any talker, such as $GPRMC and $GNGGA, in
+// NMEA 0183 data as a track. RMC sentences give the date and time of each fix, GGA
+// sentences with the same time give its altitude and fix quality. Fixes which either
+// sentence marks invalid are dropped and end the current track segment. Sentences with
+// a missing or incorrect checksum and other sentence types are skipped.
+func parseNMEA(r io.Reader) (*gpx.GPX, error) {
+	var (
+		track   gpx.GPXTrack
+		segment gpx.GPXTrackSegment
+		epoch   nmeaEpoch
+		// lastRMC is used to date fixes with only a GGA sentence
+		lastRMC *nmeaRMC
+	)
+
+	closeSegment := func() {
+		if len(segment.Points) > 0 {
+			track.Segments = append(track.Segments, segment)
+		}
+		segment = gpx.GPXTrackSegment{}
+	}
+	flush := func() {
+		if epoch.rmc != nil && epoch.rmc.valid {
+			lastRMC = epoch.rmc
+		}
+
+		point, ok := epoch.point(lastRMC)
+		if ok {
+			segment.Points = append(segment.Points, point)
+		} else if epoch.timeOfDay != "" {
+			closeSegment()
+		}
+		epoch = nmeaEpoch{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields, ok := nmeaFields(scanner.Text())
+		if !ok || len(fields[0]) != 5 {
+			continue
+		}
+
+		sentence := fields[0][2:]
+		if sentence != "RMC" && sentence != "GGA" {
+			continue
+		}
+		if len(fields) < 2 || fields[1] == "" {
+			continue
+		}
+
+		if fields[1] != epoch.timeOfDay {
+			flush()
+			epoch.timeOfDay = fields[1]
+		}
+
+		var err error
+		switch sentence {
+		case "RMC":
+			epoch.rmc, err = parseNMEARMC(fields)
+		case "GGA":
+			epoch.gga, err = parseNMEAGGA(fields)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s sentence: %w", fields[0], err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NMEA: %w", err)
+	}
+	flush()
+	closeSegment()
+
+	return &gpx.GPX{Tracks: []gpx.GPXTrack{track}}, nil
+}
+
+// point returns the fix for the epoch, ok is false if the fix is invalid or can't be
+// dated
+func (e nmeaEpoch) point(lastRMC *nmeaRMC) (gpx.GPXPoint, bool) {
+	if e.rmc != nil && !e.rmc.valid {
+		return gpx.GPXPoint{}, false
+	}
+	if e.gga != nil && !nmeaValidQuality(e.gga.quality) {
+		return gpx.GPXPoint{}, false
+	}
+
+	var point gpx.GPXPoint
+	switch {
+	case e.rmc != nil:
+		point = gpx.GPXPoint{Point: e.rmc.point, Timestamp: e.rmc.timestamp}
+	case e.gga != nil && lastRMC != nil:
+		// GGA sentences have no date, so the date of the last RMC sentence is used,
+		// moving to the next day if the time of day has wrapped around midnight
+		date := lastRMC.timestamp.Truncate(24 * time.Hour)
+		timestamp := date.Add(e.gga.timeOfDay)
+		if timestamp.Before(lastRMC.timestamp) {
+			timestamp = timestamp.AddDate(0, 0, 1)
+		}
+		point = gpx.GPXPoint{Point: e.gga.point, Timestamp: timestamp}
+	default:
+		return gpx.GPXPoint{}, false
+	}
+
+	if e.gga != nil {
+		if e.gga.altitude != nil {
+			point.Elevation = *gpx.NewNullableFloat64(*e.gga.altitude)
+		}
+		point.TypeOfGpsFix = nmeaFixType(e.gga.quality)
+		if e.gga.satellites != nil {
+			point.Satellites = *gpx.NewNullableInt(*e.gga.satellites)
+		}
+		if e.gga.hdop != nil {
+			point.HorizontalDilution = *gpx.NewNullableFloat64(*e.gga.hdop)
+		}
+	}
+
+	return point, true
+}
+
+// nmeaFields returns the comma separated fields of a sentence, ok is false if the line
+// isn't a sentence or its checksum doesn't match
+func nmeaFields(line string) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "$") {
+		return nil, false
+	}
+
+	star := strings.LastIndexByte(line, '*')
+	if star < 0 || len(line) != star+3 {
+		return nil, false
+	}
+	checksum, err := strconv.ParseUint(line[star+1:], 16, 8)
+	if err != nil {
+		return nil, false
+	}
+
+	body := line[1:star]
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	if sum != byte(checksum) {
+		return nil, false
+	}
+
+	return strings.Split(body, ","), true
+}
+
+// parseNMEARMC parses
+// $--RMC,hhmmss.ss,status,llll.ll,a,yyyyy.yy,a,speed,course,ddmmyy,variation,a*hh
+func parseNMEARMC(fields []string) (*nmeaRMC, error) {
+	if len(fields) < 10 {
+		return nil, fmt.Errorf("expected at least 10 fields, got %d", len(fields))
+	}
+
+	// V is a void fix, the other fields may be empty
+	if fields[2] != "A" {
+		return &nmeaRMC{}, nil
+	}
+
+	point, err := parseNMEAPosition(fields[3:7])
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse("020106", fields[9])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse date %q: %w", fields[9], err)
+	}
+	timeOfDay, err := parseNMEATimeOfDay(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &nmeaRMC{valid: true, point: point, timestamp: date.Add(timeOfDay)}, nil
+}
+
+// parseNMEAGGA parses
+// $--GGA,hhmmss.ss,llll.ll,a,yyyyy.yy,a,quality,satellites,hdop,altitude,M,geoid,M,age,station*hh
+func parseNMEAGGA(fields []string) (*nmeaGGA, error) {
+	if len(fields) < 11 {
+		return nil, fmt.Errorf("expected at least 11 fields, got %d", len(fields))
+	}
+
+	quality, err := strconv.Atoi(fields[6])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fix quality %q: %w", fields[6], err)
+	}
+	// the other fields may be empty when there's no fix
+	if !nmeaValidQuality(quality) {
+		return &nmeaGGA{quality: quality}, nil
+	}
+
+	gga := &nmeaGGA{quality: quality}
+
+	gga.point, err = parseNMEAPosition(fields[2:6])
+	if err != nil {
+		return nil, err
+	}
+	gga.timeOfDay, err = parseNMEATimeOfDay(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if fields[7] != "" {
+		satellites, err := strconv.Atoi(fields[7])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse satellites %q: %w", fields[7], err)
+		}
+		gga.satellites = &satellites
+	}
+	if fields[8] != "" {
+		hdop, err := strconv.ParseFloat(fields[8], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse HDOP %q: %w", fields[8], err)
+		}
+		gga.hdop = &hdop
+	}
+	if fields[9] != "" {
+		altitude, err := strconv.ParseFloat(fields[9], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse altitude %q: %w", fields[9], err)
+		}
+		gga.altitude = &altitude
+	}
+
+	return gga, nil
+}
+
+// parseNMEAPosition parses the latitude, N/S, longitude and E/W fields of a sentence
+func parseNMEAPosition(fields []string) (gpx.Point, error) {
+	lat, err := parseNMEACoordinate(fields[0], fields[1], "N", "S")
+	if err != nil {
+		return gpx.Point{}, fmt.Errorf("invalid latitude: %w", err)
+	}
+	lon, err := parseNMEACoordinate(fields[2], fields[3], "E", "W")
+	if err != nil {
+		return gpx.Point{}, fmt.Errorf("invalid longitude: %w", err)
+	}
+
+	return gpx.Point{Latitude: lat, Longitude: lon}, nil
+}
+
+// parseNMEACoordinate parses a coordinate in degrees and decimal minutes, such as
+// 5130.0000 for 51.5
+func parseNMEACoordinate(value, hemisphere, positive, negative string) (float64, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q: %w", value, err)
+	}
+
+	degrees := math.Floor(v / 100)
+	decimal := degrees + (v-degrees*100)/60
+
+	switch hemisphere {
+	case positive:
+		return decimal, nil
+	case negative:
+		return -decimal, nil
+	}
+
+	return 0, fmt.Errorf("unknown hemisphere %q", hemisphere)
+}
+
+// parseNMEATimeOfDay parses a UTC time of day in the form hhmmss.ss
+func parseNMEATimeOfDay(value string) (time.Duration, error) {
+	if len(value) < 6 {
+		return 0, fmt.Errorf("time %q was not in the form hhmmss.ss", value)
+	}
+
+	hours, err := strconv.Atoi(value[0:2])
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse time %q: %w", value, err)
+	}
+	minutes, err := strconv.Atoi(value[2:4])
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse time %q: %w", value, err)
+	}
+	seconds, err := strconv.ParseFloat(value[4:], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse time %q: %w", value, err)
+	}
+
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(math.Round(seconds*1000))*time.Millisecond, nil
+}
+
+// nmeaValidQuality reports whether a GGA fix quality is a position fix. 0 is no fix, and
+// 6 to 8 are estimated, manually entered and simulated positions.
+func nmeaValidQuality(quality int) bool {
+	return quality >= 1 && quality <= 5
+}
+
+// nmeaFixType returns the GPX fix type for a GGA fix quality, which is empty for a GPS fix
+// as GGA sentences don't say whether it's 2D or 3D
+func nmeaFixType(quality int) string {
+	switch quality {
+	case 2, 4, 5:
+		// differential and RTK fixes
+		return "dgps"
+	case 3:
+		return "pps"
+	}
+
+	return ""
+}
+
+// sniffNMEA reports whether head starts with an NMEA sentence
+func sniffNMEA(head []byte) bool {
+	head = bytes.TrimSpace(head)
+
+	return len(head) > 7 && head[0] == '$' && head[6] == ','
+}
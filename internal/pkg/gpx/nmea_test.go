@@ -0,0 +1,129 @@
+package gpx
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNMEA(t *testing.T) {
+	file, err := os.Open("fixtures/logger.nmea")
+	require.NoError(t, err)
+	defer file.Close()
+
+	data, err := parseNMEA(file)
+	require.NoError(t, err)
+
+	type expectedPoint struct {
+		Time      time.Time
+		Latitude  float64
+		Elevation float64
+		FixType   string
+	}
+
+	midnight := time.Date(2022, time.August, 4, 0, 0, 0, 0, time.UTC)
+
+	// the void fix at 00:00:03 ends the first segment and the estimated fix at 00:00:04
+	// is dropped
+	expectedSegments := [][]expectedPoint{
+		{
+			{Time: midnight.Add(-2 * time.Second), Latitude: 51.5, Elevation: 10},
+			// the GGA sentence comes before the RMC sentence
+			{Time: midnight.Add(-time.Second), Latitude: 51.5001, Elevation: 11, FixType: "dgps"},
+			// only a GGA sentence, dated from the previous day's RMC sentence
+			{Time: midnight, Latitude: 51.5002, Elevation: 12},
+			{Time: midnight.Add(time.Second), Latitude: 51.5003, Elevation: 13},
+			// the RMC sentences have an incorrect and a missing checksum
+			{Time: midnight.Add(2 * time.Second), Latitude: 51.5004, Elevation: 14},
+		},
+		{
+			{Time: midnight.Add(5500 * time.Millisecond), Latitude: 51.5007, Elevation: 17.5},
+		},
+	}
+
+	require.Len(t, data.Tracks, 1)
+	segments := data.Tracks[0].Segments
+	require.Len(t, segments, len(expectedSegments))
+
+	for i, expectedPoints := range expectedSegments {
+		require.Len(t, segments[i].Points, len(expectedPoints))
+		for j, expected := range expectedPoints {
+			p := segments[i].Points[j]
+			assert.Equal(t, expected.Time, p.Timestamp)
+			assert.InDelta(t, expected.Latitude, p.Latitude, 0.0000001)
+			assert.InDelta(t, -0.12, p.Longitude, 0.0000001)
+			assert.Equal(t, expected.Elevation, p.Elevation.Value())
+			assert.Equal(t, expected.FixType, p.TypeOfGpsFix)
+		}
+	}
+}
+
+func TestParseNMEAErrors(t *testing.T) {
+	testCases := map[string]struct {
+		Sentence      string
+		ExpectedError string
+	}{
+		"invalid date": {
+			Sentence:      "$GPRMC,120000.000,A,5130.0000,N,00007.2000,W,0.5,90.0,320822,,,A*48",
+			ExpectedError: `failed to parse GPRMC sentence: failed to parse date "320822": parsing time "320822": day out of range`,
+		},
+		"invalid hemisphere": {
+			Sentence:      "$GPGGA,120000.000,5130.0000,X,00007.2000,W,1,08,0.9,10.0,M,46.9,M,,*62",
+			ExpectedError: `failed to parse GPGGA sentence: invalid latitude: unknown hemisphere "X"`,
+		},
+		"too few fields": {
+			Sentence:      "$GPRMC,120000.000,A,5130.0000,N*70",
+			ExpectedError: "failed to parse GPRMC sentence: expected at least 10 fields, got 5",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseNMEA(strings.NewReader(testCase.Sentence))
+			assert.EqualError(t, err, testCase.ExpectedError)
+		})
+	}
+}
+
+func TestNMEAFields(t *testing.T) {
+	testCases := map[string]struct {
+		Line           string
+		ExpectedFields []string
+		ExpectedOK     bool
+	}{
+		"valid": {
+			Line:           "$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*39\r",
+			ExpectedFields: strings.Split("GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1", ","),
+			ExpectedOK:     true,
+		},
+		"lower case checksum": {
+			Line:           "$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.2*3a",
+			ExpectedFields: strings.Split("GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.2", ","),
+			ExpectedOK:     true,
+		},
+		"incorrect checksum": {
+			Line: "$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*38",
+		},
+		"missing checksum": {
+			Line: "$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1",
+		},
+		"truncated": {
+			Line: "$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*3",
+		},
+		"not a sentence": {
+			Line: "2022-08-03 12:00:00 logger started",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fields, ok := nmeaFields(testCase.Line)
+			assert.Equal(t, testCase.ExpectedOK, ok)
+			assert.Equal(t, testCase.ExpectedFields, fields)
+		})
+	}
+}
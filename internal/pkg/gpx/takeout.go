@@ -0,0 +1,364 @@
+package gpx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// NewGPXDatasetFromTakeoutRecords loads the points in a Google Takeout Records.json
+// location history file. The file is decoded one location at a time, so it isn't read
+// into memory in full.
+func NewGPXDatasetFromTakeoutRecords(r io.Reader, opts ReadOptions) (GPXDataset, error) {
+	return newGPXDatasetFromParser(r, opts, parseTakeoutRecords)
+}
+
+// NewGPXDatasetFromTakeoutTimeline loads the points in a Google Timeline.json export of
+// semantic segments and raw signals. The file is decoded one segment or signal at a
+// time, so it isn't read into memory in full.
+func NewGPXDatasetFromTakeoutTimeline(r io.Reader, opts ReadOptions) (GPXDataset, error) {
+	return newGPXDatasetFromParser(r, opts, parseTakeoutTimeline)
+}
+
+func newGPXDatasetFromParser(
+	r io.Reader,
+	opts ReadOptions,
+	parse func(io.Reader, ReadOptions) (*gpx.GPX, error),
+) (GPXDataset, error) {
+	ds := GPXDataset{}
+
+	data, err := parse(r, opts)
+	if err != nil {
+		return GPXDataset{}, err
+	}
+
+	ds.data = append(ds.data, data)
+	ds.buildIndex()
+
+	return ds, nil
+}
+
+// takeoutRecord is a location in Records.json
+type takeoutRecord struct {
+	LatitudeE7  *int64   `json:"latitudeE7"`
+	LongitudeE7 *int64   `json:"longitudeE7"`
+	Accuracy    *float64 `json:"accuracy"`
+	Altitude    *float64 `json:"altitude"`
+	// Timestamp is used by newer exports, older exports have TimestampMs instead
+	Timestamp   string `json:"timestamp"`
+	TimestampMs string `json:"timestampMs"`
+}
+
+// parseTakeoutRecords reads the locations in Records.json as a single track segment.
+// Locations less accurate than the MaxAccuracy option are dropped.
+func parseTakeoutRecords(r io.Reader, opts ReadOptions) (*gpx.GPX, error) {
+	var segment gpx.GPXTrackSegment
+
+	err := streamJSONArrays(r, map[string]func(*json.Decoder) error{
+		"locations": func(d *json.Decoder) error {
+			var record takeoutRecord
+			err := d.Decode(&record)
+			if err != nil {
+				return fmt.Errorf("failed to decode location: %w", err)
+			}
+
+			if record.LatitudeE7 == nil || record.LongitudeE7 == nil || !opts.keep(record.Accuracy) {
+				return nil
+			}
+
+			timestamp, err := record.time()
+			if err != nil {
+				return err
+			}
+
+			point := gpx.GPXPoint{
+				Point: gpx.Point{
+					Latitude:  fromE7(*record.LatitudeE7),
+					Longitude: fromE7(*record.LongitudeE7),
+				},
+				Timestamp: timestamp,
+			}
+			if record.Altitude != nil {
+				point.Elevation = *gpx.NewNullableFloat64(*record.Altitude)
+			}
+
+			segment.Points = append(segment.Points, point)
+
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &gpx.GPX{
+		Tracks: []gpx.GPXTrack{{Name: "Records", Segments: []gpx.GPXTrackSegment{segment}}},
+	}, nil
+}
+
+func (r takeoutRecord) time() (time.Time, error) {
+	if r.Timestamp != "" {
+		return parseTakeoutTime(r.Timestamp)
+	}
+
+	ms, err := strconv.ParseInt(r.TimestampMs, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse timestampMs %q: %w", r.TimestampMs, err)
+	}
+
+	return time.UnixMilli(ms).UTC(), nil
+}
+
+// fromE7 returns the degrees for a coordinate in degrees * 10^7. Some exports have
+// coordinates which overflowed a signed 32 bit integer, these are wrapped back into range.
+func fromE7(e7 int64) float64 {
+	if e7 > 1800000000 {
+		e7 -= 1 << 32
+	}
+
+	return float64(e7) / 1e7
+}
+
+// timelineSegment is a semantic segment in Timeline.json, which is either a visit to a
+// place, an activity between places, or a path of timed points
+type timelineSegment struct {
+	StartTime    string `json:"startTime"`
+	EndTime      string `json:"endTime"`
+	TimelinePath []struct {
+		Point string `json:"point"`
+		Time  string `json:"time"`
+	} `json:"timelinePath"`
+	Visit *struct {
+		TopCandidate struct {
+			PlaceLocation struct {
+				LatLng string `json:"latLng"`
+			} `json:"placeLocation"`
+		} `json:"topCandidate"`
+	} `json:"visit"`
+	Activity *struct {
+		Start struct {
+			LatLng string `json:"latLng"`
+		} `json:"start"`
+		End struct {
+			LatLng string `json:"latLng"`
+		} `json:"end"`
+	} `json:"activity"`
+}
+
+// timelineSignal is a raw signal in Timeline.json, only position signals are used
+type timelineSignal struct {
+	Position *struct {
+		LatLng         string   `json:"LatLng"`
+		AccuracyMeters *float64 `json:"accuracyMeters"`
+		AltitudeMeters *float64 `json:"altitudeMeters"`
+		Timestamp      string   `json:"timestamp"`
+	} `json:"position"`
+}
+
+// parseTakeoutTimeline reads the semantic segments in Timeline.json as a track with a
+// segment for each, and the raw position signals as another track. Visits are points at
+// the place at the start and end of the visit, activities are points at their start and
+// end. Position signals less accurate than the MaxAccuracy option are dropped.
+func parseTakeoutTimeline(r io.Reader, opts ReadOptions) (*gpx.GPX, error) {
+	semantic := gpx.GPXTrack{Name: "Semantic segments"}
+	var signals gpx.GPXTrackSegment
+
+	err := streamJSONArrays(r, map[string]func(*json.Decoder) error{
+		"semanticSegments": func(d *json.Decoder) error {
+			var s timelineSegment
+			err := d.Decode(&s)
+			if err != nil {
+				return fmt.Errorf("failed to decode semantic segment: %w", err)
+			}
+
+			segment, err := s.points()
+			if err != nil {
+				return err
+			}
+			if len(segment.Points) > 0 {
+				semantic.Segments = append(semantic.Segments, segment)
+			}
+
+			return nil
+		},
+		"rawSignals": func(d *json.Decoder) error {
+			var s timelineSignal
+			err := d.Decode(&s)
+			if err != nil {
+				return fmt.Errorf("failed to decode raw signal: %w", err)
+			}
+
+			if s.Position == nil || !opts.keep(s.Position.AccuracyMeters) {
+				return nil
+			}
+
+			point, err := timelinePoint(s.Position.LatLng, s.Position.Timestamp)
+			if err != nil {
+				return err
+			}
+			if s.Position.AltitudeMeters != nil {
+				point.Elevation = *gpx.NewNullableFloat64(*s.Position.AltitudeMeters)
+			}
+
+			signals.Points = append(signals.Points, point)
+
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := &gpx.GPX{Tracks: []gpx.GPXTrack{semantic}}
+	if len(signals.Points) > 0 {
+		data.Tracks = append(data.Tracks, gpx.GPXTrack{Name: "Raw signals", Segments: []gpx.GPXTrackSegment{signals}})
+	}
+
+	return data, nil
+}
+
+// points returns the timed points in the semantic segment
+func (s timelineSegment) points() (gpx.GPXTrackSegment, error) {
+	var segment gpx.GPXTrackSegment
+
+	add := func(latLng, timestamp string) error {
+		if latLng == "" {
+			return nil
+		}
+
+		point, err := timelinePoint(latLng, timestamp)
+		if err != nil {
+			return err
+		}
+		segment.Points = append(segment.Points, point)
+
+		return nil
+	}
+
+	var err error
+	switch {
+	case s.Visit != nil:
+		latLng := s.Visit.TopCandidate.PlaceLocation.LatLng
+		err = add(latLng, s.StartTime)
+		if err == nil {
+			err = add(latLng, s.EndTime)
+		}
+	case s.Activity != nil:
+		err = add(s.Activity.Start.LatLng, s.StartTime)
+		if err == nil {
+			err = add(s.Activity.End.LatLng, s.EndTime)
+		}
+	default:
+		for _, p := range s.TimelinePath {
+			err = add(p.Point, p.Time)
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	return segment, err
+}
+
+// timelinePoint returns a point from a Timeline.json position such as
+// "51.5000000°, -0.1200000°" and an ISO 8601 timestamp
+func timelinePoint(latLng, timestamp string) (gpx.GPXPoint, error) {
+	t, err := parseTakeoutTime(timestamp)
+	if err != nil {
+		return gpx.GPXPoint{}, err
+	}
+
+	fields := strings.Split(strings.TrimPrefix(latLng, "geo:"), ",")
+	if len(fields) != 2 {
+		return gpx.GPXPoint{}, fmt.Errorf("position %q was not in the form latitude, longitude", latLng)
+	}
+
+	var values [2]float64
+	for i, f := range fields {
+		values[i], err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(f), "°"), 64)
+		if err != nil {
+			return gpx.GPXPoint{}, fmt.Errorf("failed to parse position %q: %w", latLng, err)
+		}
+	}
+
+	return gpx.GPXPoint{
+		Point:     gpx.Point{Latitude: values[0], Longitude: values[1]},
+		Timestamp: t,
+	}, nil
+}
+
+func parseTakeoutTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse timestamp %q: %w", value, err)
+	}
+
+	return t.UTC(), nil
+}
+
+// streamJSONArrays decodes a JSON object, calling the handler for a field once for each
+// element of the array it contains. The handler must decode a single value. Fields
+// without a handler are skipped.
+func streamJSONArrays(r io.Reader, handlers map[string]func(*json.Decoder) error) error {
+	d := json.NewDecoder(r)
+
+	err := expectJSONDelim(d, '{')
+	if err != nil {
+		return err
+	}
+
+	for d.More() {
+		token, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read JSON: %w", err)
+		}
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("expected JSON object key, got %v", token)
+		}
+
+		handler, ok := handlers[key]
+		if !ok {
+			var skip json.RawMessage
+			err = d.Decode(&skip)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", key, err)
+			}
+			continue
+		}
+
+		err = expectJSONDelim(d, '[')
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		for d.More() {
+			err = handler(d)
+			if err != nil {
+				return err
+			}
+		}
+		err = expectJSONDelim(d, ']')
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+	}
+
+	return expectJSONDelim(d, '}')
+}
+
+func expectJSONDelim(d *json.Decoder, delim json.Delim) error {
+	token, err := d.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read JSON: %w", err)
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s in JSON, got %v", delim, token)
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package gpx
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestNewGPXDatasetFromTakeoutRecords(t *testing.T) {
+	testCases := map[string]struct {
+		Options            ReadOptions
+		ExpectedPointCount int
+	}{
+		"all points": {
+			ExpectedPointCount: 3,
+		},
+		"points within max accuracy": {
+			Options:            ReadOptions{MaxAccuracy: 100},
+			ExpectedPointCount: 2,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open("fixtures/Records.json")
+			require.NoError(t, err)
+			defer file.Close()
+
+			gpxDataset, err := NewGPXDatasetFromTakeoutRecords(file, testCase.Options)
+			require.NoError(t, err)
+
+			points := gpxDataset.AllPoints()
+			require.Len(t, points, testCase.ExpectedPointCount)
+
+			assert.Equal(t, time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC), points[0].Timestamp)
+			assert.Equal(t, gpx.Point{
+				Latitude:  51.5,
+				Longitude: -0.12,
+				Elevation: *gpx.NewNullableFloat64(10),
+			}, points[0].Point)
+
+			// the longitude overflowed in the export
+			last := points[len(points)-1]
+			assert.Equal(t, time.Date(2022, time.August, 3, 9, 1, 0, 500000000, time.UTC), last.Timestamp)
+			assert.Equal(t, gpx.Point{
+				Latitude:  51.5009,
+				Longitude: -0.12,
+				Elevation: *gpx.NewNullableFloat64(12),
+			}, last.Point)
+		})
+	}
+}
+
+func TestNewGPXDatasetFromTakeoutTimeline(t *testing.T) {
+	testCases := map[string]struct {
+		Options            ReadOptions
+		ExpectedPointCount int
+		ExpectedLastTime   time.Time
+	}{
+		"all points": {
+			ExpectedPointCount: 7,
+			ExpectedLastTime:   time.Date(2022, time.August, 3, 8, 50, 0, 0, time.UTC),
+		},
+		"points within max accuracy": {
+			Options:            ReadOptions{MaxAccuracy: 100},
+			ExpectedPointCount: 6,
+			ExpectedLastTime:   time.Date(2022, time.August, 3, 8, 45, 0, 0, time.UTC),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open("fixtures/Timeline.json")
+			require.NoError(t, err)
+			defer file.Close()
+
+			gpxDataset, err := NewGPXDatasetFromTakeoutTimeline(file, testCase.Options)
+			require.NoError(t, err)
+
+			points := gpxDataset.AllPoints()
+			require.Len(t, points, testCase.ExpectedPointCount)
+			assert.Equal(t, testCase.ExpectedLastTime, points[len(points)-1].Timestamp)
+
+			// times during a visit match the place visited
+			m, err := gpxDataset.Match(time.Date(2022, time.August, 3, 8, 15, 0, 0, time.UTC))
+			require.NoError(t, err)
+			assert.Equal(t, 51.5, m.Point.Latitude)
+			assert.Equal(t, -0.12, m.Point.Longitude)
+
+			// points on the timeline path
+			m, err = gpxDataset.Match(time.Date(2022, time.August, 3, 8, 35, 0, 0, time.UTC))
+			require.NoError(t, err)
+			assert.Equal(t, 51.505, m.Point.Latitude)
+			assert.Equal(t, -0.1, m.Point.Longitude)
+		})
+	}
+}
+
+func TestNewGPXDatasetFromDiskTakeout(t *testing.T) {
+	testCases := map[string]struct {
+		Path               string
+		Options            ReadOptions
+		ExpectedPointCount int
+	}{
+		"records": {
+			Path:               "fixtures/Records.json",
+			ExpectedPointCount: 3,
+		},
+		"records with max accuracy": {
+			Path:               "fixtures/Records.json",
+			Options:            ReadOptions{MaxAccuracy: 100},
+			ExpectedPointCount: 2,
+		},
+		"timeline": {
+			Path:               "fixtures/Timeline.json",
+			ExpectedPointCount: 7,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDiskWithOptions(testCase.Options, testCase.Path)
+			require.NoError(t, err)
+
+			assert.Len(t, gpxDataset.AllPoints(), testCase.ExpectedPointCount)
+		})
+	}
+}
+
+func TestParseTakeoutErrors(t *testing.T) {
+	testCases := map[string]struct {
+		Data          string
+		Parse         func(io.Reader, ReadOptions) (*gpx.GPX, error)
+		ExpectedError string
+	}{
+		"records not an object": {
+			Parse:         parseTakeoutRecords,
+			Data:          `[{"latitudeE7": 515000000}]`,
+			ExpectedError: "expected { in JSON, got [",
+		},
+		"records with an invalid timestamp": {
+			Parse:         parseTakeoutRecords,
+			Data:          `{"locations": [{"latitudeE7": 515000000, "longitudeE7": 0, "timestamp": "yesterday"}]}`,
+			ExpectedError: `failed to parse timestamp "yesterday"`,
+		},
+		"records with an invalid timestampMs": {
+			Parse:         parseTakeoutRecords,
+			Data:          `{"locations": [{"latitudeE7": 515000000, "longitudeE7": 0, "timestampMs": "soon"}]}`,
+			ExpectedError: `failed to parse timestampMs "soon"`,
+		},
+		"timeline with an invalid position": {
+			Parse:         parseTakeoutTimeline,
+			Data:          `{"rawSignals": [{"position": {"LatLng": "51.5°", "timestamp": "2022-08-03T09:00:00Z"}}]}`,
+			ExpectedError: `position "51.5°" was not in the form latitude, longitude`,
+		},
+		"timeline with a truncated segment": {
+			Parse:         parseTakeoutTimeline,
+			Data:          `{"semanticSegments": [{"startTime": "2022-08-03T09:00:00Z"`,
+			ExpectedError: "failed to decode semantic segment",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := testCase.Parse(strings.NewReader(testCase.Data), ReadOptions{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.ExpectedError)
+		})
+	}
+}
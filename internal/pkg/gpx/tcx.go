@@ -0,0 +1,111 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// tcxTrack is a Track element in a TCX file, which is in a lap of an activity or in a course
+type tcxTrack struct {
+	Trackpoints []struct {
+		Time     string `xml:"Time"`
+		Position *struct {
+			LatitudeDegrees  float64 `xml:"LatitudeDegrees"`
+			LongitudeDegrees float64 `xml:"LongitudeDegrees"`
+		} `xml:"Position"`
+		AltitudeMeters *float64 `xml:"AltitudeMeters"`
+	} `xml:"Trackpoint"`
+}
+
+type tcxDatabase struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		ID    string `xml:"Id"`
+		Laps  []struct {
+			Tracks []tcxTrack `xml:"Track"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+	Courses []struct {
+		Name   string     `xml:"Name"`
+		Tracks []tcxTrack `xml:"Track"`
+	} `xml:"Courses>Course"`
+}
+
+// parseTCX reads the trackpoints in TCX data, each activity or course becomes a GPX track
+// with a segment for each of its Track elements. A lap's first Track continues the segment
+// of the lap before, since a new lap isn't a break in recording, while further Tracks in
+// a lap follow a pause. Trackpoints without a position, such as those from sensors while
+// there was no fix, are skipped.
+func parseTCX(r io.Reader) (*gpx.GPX, error) {
+	var db tcxDatabase
+	err := xml.NewDecoder(r).Decode(&db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TCX: %w", err)
+	}
+
+	data := &gpx.GPX{}
+	for _, activity := range db.Activities {
+		track := gpx.GPXTrack{Name: activity.ID, Type: activity.Sport}
+		for _, lap := range activity.Laps {
+			for i, t := range lap.Tracks {
+				segment, err := t.segment()
+				if err != nil {
+					return nil, err
+				}
+				if i == 0 && len(track.Segments) > 0 {
+					last := &track.Segments[len(track.Segments)-1]
+					last.Points = append(last.Points, segment.Points...)
+					continue
+				}
+				track.Segments = append(track.Segments, segment)
+			}
+		}
+		data.Tracks = append(data.Tracks, track)
+	}
+	for _, course := range db.Courses {
+		track := gpx.GPXTrack{Name: course.Name}
+		for _, t := range course.Tracks {
+			segment, err := t.segment()
+			if err != nil {
+				return nil, err
+			}
+			track.Segments = append(track.Segments, segment)
+		}
+		data.Tracks = append(data.Tracks, track)
+	}
+
+	return data, nil
+}
+
+func (t tcxTrack) segment() (gpx.GPXTrackSegment, error) {
+	var segment gpx.GPXTrackSegment
+	for _, tp := range t.Trackpoints {
+		if tp.Position == nil {
+			continue
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, tp.Time)
+		if err != nil {
+			return segment, fmt.Errorf("failed to parse trackpoint time %q: %w", tp.Time, err)
+		}
+
+		point := gpx.GPXPoint{
+			Point: gpx.Point{
+				Latitude:  tp.Position.LatitudeDegrees,
+				Longitude: tp.Position.LongitudeDegrees,
+			},
+			Timestamp: timestamp.UTC(),
+		}
+		if tp.AltitudeMeters != nil {
+			point.Elevation = *gpx.NewNullableFloat64(*tp.AltitudeMeters)
+		}
+
+		segment.Points = append(segment.Points, point)
+	}
+
+	return segment, nil
+}
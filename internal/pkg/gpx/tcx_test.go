@@ -0,0 +1,127 @@
+package gpx
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestParseTCX(t *testing.T) {
+	file, err := os.Open("fixtures/ride.tcx")
+	require.NoError(t, err)
+	defer file.Close()
+
+	data, err := parseTCX(file)
+	require.NoError(t, err)
+
+	require.Len(t, data.Tracks, 1)
+	assert.Equal(t, "2022-08-03T09:00:00Z", data.Tracks[0].Name)
+	assert.Equal(t, "Biking", data.Tracks[0].Type)
+
+	// the laps are joined in one segment, the trackpoint without a position is skipped
+	segments := data.Tracks[0].Segments
+	require.Len(t, segments, 1)
+	require.Len(t, segments[0].Points, 3)
+
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC), segments[0].Points[0].Timestamp)
+	assert.Equal(t, gpx.Point{
+		Latitude:  51.5,
+		Longitude: -0.12,
+		Elevation: *gpx.NewNullableFloat64(10),
+	}, segments[0].Points[0].Point)
+
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 0, 30, 0, time.UTC), segments[0].Points[1].Timestamp)
+	assert.False(t, segments[0].Points[1].Elevation.NotNull())
+
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 10, 0, 0, time.UTC), segments[0].Points[2].Timestamp)
+}
+
+func TestParseTCXPausedLap(t *testing.T) {
+	trackpoint := func(t string) string {
+		return `<Trackpoint><Time>` + t + `</Time>
+			<Position><LatitudeDegrees>51.5</LatitudeDegrees><LongitudeDegrees>-0.12</LongitudeDegrees></Position>
+		</Trackpoint>`
+	}
+
+	tcx := `<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+		<Activities><Activity Sport="Running"><Id>2022-08-03T09:00:00Z</Id>
+			<Lap><Track>` + trackpoint("2022-08-03T09:00:00Z") + `</Track></Lap>
+			<Lap>
+				<Track>` + trackpoint("2022-08-03T09:10:00Z") + `</Track>
+				<Track>` + trackpoint("2022-08-03T09:30:00Z") + `</Track>
+			</Lap>
+		</Activity></Activities>
+	</TrainingCenterDatabase>`
+
+	data, err := parseTCX(strings.NewReader(tcx))
+	require.NoError(t, err)
+
+	// the second lap continues the first, and its second track follows a pause
+	segments := data.Tracks[0].Segments
+	require.Len(t, segments, 2)
+	require.Len(t, segments[0].Points, 2)
+	require.Len(t, segments[1].Points, 1)
+	assert.Equal(t, time.Date(2022, time.August, 3, 9, 30, 0, 0, time.UTC), segments[1].Points[0].Timestamp)
+}
+
+func TestParseTCXErrors(t *testing.T) {
+	testCases := map[string]struct {
+		Data          string
+		ExpectedError string
+	}{
+		"invalid time": {
+			Data: `<TrainingCenterDatabase><Activities><Activity><Lap><Track><Trackpoint>
+				<Time>yesterday</Time>
+				<Position><LatitudeDegrees>51.5</LatitudeDegrees><LongitudeDegrees>-0.12</LongitudeDegrees></Position>
+			</Trackpoint></Track></Lap></Activity></Activities></TrainingCenterDatabase>`,
+			ExpectedError: `failed to parse trackpoint time "yesterday"`,
+		},
+		"invalid XML": {
+			Data:          `<TrainingCenterDatabase><Activities>`,
+			ExpectedError: "failed to read TCX",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseTCX(strings.NewReader(testCase.Data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.ExpectedError)
+		})
+	}
+}
+
+func TestNewGPXDatasetFromDiskGarmin(t *testing.T) {
+	testCases := map[string]struct {
+		Paths              []string
+		ExpectedPointCount int
+	}{
+		"fit": {
+			Paths:              []string{"fixtures/ride.fit"},
+			ExpectedPointCount: 6,
+		},
+		"tcx": {
+			Paths:              []string{"fixtures/ride.tcx"},
+			ExpectedPointCount: 3,
+		},
+		// points at the same time in both files are only loaded once
+		"fit and tcx": {
+			Paths:              []string{"fixtures/ride.fit", "fixtures/ride.tcx"},
+			ExpectedPointCount: 7,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gpxDataset, err := NewGPXDatasetFromDisk(testCase.Paths...)
+			require.NoError(t, err)
+
+			assert.Len(t, gpxDataset.AllPoints(), testCase.ExpectedPointCount)
+		})
+	}
+}
@@ -3,8 +3,6 @@ package gpxfetch
 import (
 	"bytes"
 	"fmt"
-	"io/fs"
-	"io/ioutil"
 	"net/http"
 	"sort"
 	"text/template"
@@ -16,10 +14,12 @@ import (
 	"github.com/charlieegan3/gpxif/internal/pkg/utils"
 )
 
-func ForImages(cfg config.Config, sourceDir string) (gpx.GPXDataset, error) {
+// ForImages fetches GPX data from the configured source covering the times of the images,
+// files which aren't images are ignored
+func ForImages(cfg config.Config, images []string) (gpx.GPXDataset, error) {
 	var gpxDataset gpx.GPXDataset
 
-	rangeStart, rangeEnd, err := determineTimeRange(sourceDir)
+	rangeStart, rangeEnd, err := determineTimeRange(images)
 	if err != nil {
 		return gpxDataset, fmt.Errorf("failed to determine time range for images: %w", err)
 	}
@@ -63,32 +63,25 @@ func ForImages(cfg config.Config, sourceDir string) (gpx.GPXDataset, error) {
 }
 
 // determineTimeRange returns the earliest and latest times from a set of images
-func determineTimeRange(sourceDir string) (time.Time, time.Time, error) {
-	var err error
+func determineTimeRange(images []string) (time.Time, time.Time, error) {
 	var start, end time.Time
 
-	var files []fs.FileInfo
-	files, err = ioutil.ReadDir(sourceDir)
-	if err != nil {
-		return start, end, fmt.Errorf("failed to list files in source directory: %s", err)
-	}
-
 	var utcTimes []time.Time
-	for _, f := range files {
-		if !utils.IsJPEGFile(f.Name()) {
+	for _, image := range images {
+		if !utils.IsImageFile(image) {
 			continue
 		}
 
-		utcTime, err := exif.GetUTC(sourceDir + "/" + f.Name())
+		utcTime, err := exif.GetUTC(image)
 		if err != nil {
-			return start, end, fmt.Errorf("failed to determine UTC time for %s: %w", f.Name(), err)
+			return start, end, fmt.Errorf("failed to determine UTC time for %s: %w", image, err)
 		}
 
 		utcTimes = append(utcTimes, utcTime)
 	}
 
 	if len(utcTimes) < 1 {
-		return start, end, fmt.Errorf("no images with UTC times found in source directory")
+		return start, end, fmt.Errorf("no images with UTC times found")
 	}
 
 	sort.Slice(utcTimes, func(i, j int) bool {
@@ -3,6 +3,7 @@ package gpxfetch
 import (
 	"fmt"
 	"github.com/charlieegan3/gpxif/internal/pkg/config"
+	"github.com/charlieegan3/gpxif/internal/pkg/discover"
 	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -35,7 +36,8 @@ func TestForImages(t *testing.T) {
 	expectedDs, err := gpx.NewGPXDatasetFromReader(strings.NewReader(rawGPXData))
 	require.NoError(t, err)
 
-	sourceDir := "../exif/fixtures"
+	images, err := discover.Paths([]string{"../exif/fixtures"}, discover.Options{})
+	require.NoError(t, err)
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, pass, ok := r.BasicAuth()
@@ -64,7 +66,7 @@ func TestForImages(t *testing.T) {
 				Password:    "pass",
 			},
 		},
-		sourceDir,
+		images,
 	)
 	require.NoError(t, err)
 
@@ -86,7 +88,10 @@ func TestDetermineRange(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			start, end, err := determineTimeRange(testCase.SourceDir)
+			images, err := discover.Paths([]string{testCase.SourceDir}, discover.Options{})
+			require.NoError(t, err)
+
+			start, end, err := determineTimeRange(images)
 			require.NoError(t, err)
 
 			assert.Equal(t, testCase.ExpectedEnd, end)
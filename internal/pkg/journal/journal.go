@@ -0,0 +1,189 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+	"github.com/charlieegan3/gpxif/internal/pkg/utils"
+	"github.com/djherbis/times"
+)
+
+// ErrModified is returned when undoing an entry for a file which has changed
+// since the entry was recorded
+var ErrModified = errors.New("file has been modified since it was updated")
+
+// Entry records the changes made to a single image so that they can be undone
+type Entry struct {
+	// Path is the image file that was updated
+	Path string `json:"path"`
+	// Hash is the SHA-256 of the file's content after the changes were made
+	Hash string `json:"hash"`
+	// ModTime is the modification time of the file before the changes were made
+	ModTime time.Time `json:"mod_time"`
+	// Changes are the EXIF changes which were made
+	Changes []exif.Change `json:"changes"`
+	// Previous are the changes which restore the values from before Changes were made
+	Previous []exif.Change `json:"previous"`
+}
+
+// NewEntry captures the current state of the image before changes are made to it.
+// Complete must be called once the changes have been made.
+//
+// Only the values of the changed keys are recorded. The thumbnail offsets and the
+// FileSource and SceneType tags removed by exif.Apply on every write are not recorded
+// and are not restored by Undo.
+func NewEntry(image string, changes []exif.Change) (Entry, error) {
+	image, err := filepath.Abs(image)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get absolute path for image: %w", err)
+	}
+
+	t, err := times.Stat(image)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get mtime for image: %w", err)
+	}
+
+	previous, err := exif.Inverse(image, changes)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get current values: %w", err)
+	}
+
+	return Entry{
+		Path:     image,
+		ModTime:  t.ModTime().UTC(),
+		Changes:  changes,
+		Previous: previous,
+	}, nil
+}
+
+// Complete records the state of the image after the changes have been made, updated is
+// the file holding the changed image. This is the entry's path unless the changes were
+// made to a copy which will replace it.
+func (e *Entry) Complete(updated string) error {
+	hash, err := utils.HashFile(updated)
+	if err != nil {
+		return fmt.Errorf("failed to hash image: %w", err)
+	}
+	e.Hash = hash
+
+	return nil
+}
+
+// Undo restores the previous values and modification time of the entry's image.
+// ErrModified is returned, and nothing is changed, if the image's content differs
+// from when the entry was recorded.
+func Undo(e Entry) error {
+	hash, err := utils.HashFile(e.Path)
+	if err != nil {
+		return fmt.Errorf("failed to hash image: %w", err)
+	}
+	if hash != e.Hash {
+		return ErrModified
+	}
+
+	err = exif.Apply(e.Path, e.Previous)
+	if err != nil {
+		return fmt.Errorf("failed to restore exif data: %w", err)
+	}
+
+	err = os.Chtimes(e.Path, e.ModTime, e.ModTime)
+	if err != nil {
+		return fmt.Errorf("failed to restore mtime: %w", err)
+	}
+
+	return nil
+}
+
+// Writer appends entries to a journal file, one JSON entry per line. Entries are
+// synced to disk as they're written so the journal is complete up to any crash.
+type Writer struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// Create opens a new journal file at path, creating any missing directories
+func Create(path string) (*Writer, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	return &Writer{path: path, f: f}, nil
+}
+
+// Path returns the path of the journal file
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write appends the entry to the journal
+func (w *Writer) Write(e Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	_, err = w.f.Write(append(b, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+
+	return w.f.Sync()
+}
+
+// Close closes the journal file
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
+
+// Load reads all the entries from a journal file
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse journal entry on line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return entries, nil
+}
+
+// DefaultPath returns a new journal path in dir based on the time
+func DefaultPath(dir string, t time.Time) string {
+	return filepath.Join(dir, t.UTC().Format("20060102T150405Z")+".jsonl")
+}
@@ -0,0 +1,153 @@
+package journal
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/djherbis/times"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+)
+
+func TestUndo(t *testing.T) {
+	testCases := map[string]struct {
+		Image           string
+		Changes         []exif.Change
+		ModifyAfter     bool
+		ExpectErr       error
+		ExpectedRestore map[string]any
+	}{
+		"restores changed and added values": {
+			Image: "../exif/fixtures/iphone.JPG",
+			Changes: []exif.Change{
+				{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 17:56:22"},
+				{IFDPath: "IFD/GPSInfo", Key: "GPSLatitude", Value: exif.RationalDegreesMinutesSecondsFromDecimal(1.5)},
+				{IFDPath: "IFD/Exif", Key: "ImageUniqueID", Value: "added"},
+			},
+			ExpectedRestore: map[string]any{
+				"DateTimeOriginal": "2022:08:03 18:56:22",
+				"ImageUniqueID":    nil,
+			},
+		},
+		"restores HEIC": {
+			Image: "../exif/fixtures/iphone.HEIC",
+			Changes: []exif.Change{
+				{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 17:57:45"},
+			},
+			ExpectedRestore: map[string]any{
+				"DateTimeOriginal": "2022:08:03 18:57:45",
+			},
+		},
+		"refuses modified file": {
+			Image: "../exif/fixtures/iphone.JPG",
+			Changes: []exif.Change{
+				{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 17:56:22"},
+			},
+			ModifyAfter: true,
+			ExpectErr:   ErrModified,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			image := copyImage(t, testCase.Image)
+
+			originalMtime := time.Date(2021, time.January, 2, 3, 4, 5, 0, time.UTC)
+			require.NoError(t, os.Chtimes(image, originalMtime, originalMtime))
+
+			entry, err := NewEntry(image, testCase.Changes)
+			require.NoError(t, err)
+
+			require.NoError(t, exif.Apply(image, testCase.Changes))
+			require.NoError(t, entry.Complete(image))
+
+			// round trip the entry through a journal file
+			journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+			w, err := Create(journalPath)
+			require.NoError(t, err)
+			require.NoError(t, w.Write(entry))
+			require.NoError(t, w.Close())
+
+			entries, err := Load(journalPath)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, entry, entries[0])
+
+			if testCase.ModifyAfter {
+				require.NoError(t, exif.SetKey(image, "IFD/Exif", "LensModel", "edited elsewhere"))
+			}
+
+			err = Undo(entries[0])
+			if testCase.ExpectErr != nil {
+				require.ErrorIs(t, err, testCase.ExpectErr)
+
+				value, err := exif.GetKey(image, "IFD/Exif", "LensModel")
+				require.NoError(t, err)
+				assert.Equal(t, "edited elsewhere", value)
+				return
+			}
+			require.NoError(t, err)
+
+			for k, v := range testCase.ExpectedRestore {
+				value, err := exif.GetKey(image, "IFD/Exif", k)
+				if v == nil {
+					require.ErrorContains(t, err, "tag not found")
+					continue
+				}
+				require.NoError(t, err)
+				assert.Equal(t, v, value)
+			}
+
+			mtime, err := times.Stat(image)
+			require.NoError(t, err)
+			assert.Equal(t, originalMtime, mtime.ModTime().UTC())
+		})
+	}
+}
+
+func TestUndoDoesNotRestoreRemovedTags(t *testing.T) {
+	image := copyImage(t, "../exif/fixtures/iphone.JPG")
+
+	_, err := exif.GetKey(image, "IFD/Exif", "SceneType")
+	require.NoError(t, err)
+
+	changes := []exif.Change{{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 17:56:22"}}
+	entry, err := NewEntry(image, changes)
+	require.NoError(t, err)
+	require.NoError(t, exif.Apply(image, changes))
+	require.NoError(t, entry.Complete(image))
+
+	require.NoError(t, Undo(entry))
+
+	value, err := exif.GetKey(image, "IFD/Exif", "DateTimeOriginal")
+	require.NoError(t, err)
+	assert.Equal(t, "2022:08:03 18:56:22", value)
+
+	// SceneType is removed by every write, including the one made by Undo
+	_, err = exif.GetKey(image, "IFD/Exif", "SceneType")
+	require.ErrorContains(t, err, "tag not found")
+}
+
+func copyImage(t *testing.T, source string) string {
+	t.Helper()
+
+	dst := filepath.Join(t.TempDir(), filepath.Base(source))
+
+	in, err := os.Open(source)
+	require.NoError(t, err)
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	require.NoError(t, err)
+
+	return dst
+}
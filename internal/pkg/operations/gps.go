@@ -2,37 +2,116 @@ package operations
 
 import (
 	"fmt"
-	dectofrac "github.com/av-elier/go-decimal-to-rational"
 	"github.com/charlieegan3/gpxif/internal/pkg/exif"
 	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
 	exifcommon "github.com/dsoprea/go-exif/v3/common"
-	"strings"
+	gpxgo "github.com/tkrajina/gpxgo/gpx"
+	"math"
+	"math/big"
 )
 
-func CheckGPSData(imageFile string, g *gpx.GPXDataset) ([]Operation, error) {
-	var operations []Operation
+const (
+	// gpsMapDatum is the datum used by GPX coordinates
+	gpsMapDatum = "WGS-84"
+	// gpsProcessingMethod records that the position came from a GPS track
+	gpsProcessingMethod = "GPS"
+)
+
+// GPSPolicy controls what happens to images which already have GPS data
+type GPSPolicy string
+
+const (
+	// GPSPolicyKeep leaves existing GPS data unchanged
+	GPSPolicyKeep GPSPolicy = "keep"
+	// GPSPolicyOverwrite always replaces existing GPS data with the GPX position
+	GPSPolicyOverwrite GPSPolicy = "overwrite"
+	// GPSPolicyIfFar replaces existing GPS data when it's further than the threshold
+	// from the GPX position
+	GPSPolicyIfFar GPSPolicy = "if-far"
+)
+
+// DefaultGPSThreshold is the distance in meters beyond which GPSPolicyIfFar replaces
+// existing GPS data
+const DefaultGPSThreshold = 100.0
 
-	gpsLatitude, err := exif.GetKey(imageFile, "IFD/GPSInfo", "GPSLatitude")
-	if err != nil && !strings.Contains(err.Error(), "tag not found") {
-		return operations, fmt.Errorf("failed to get GPSLatitude: %w", err)
+// ParseGPSPolicy returns the GPSPolicy with the given name
+func ParseGPSPolicy(name string) (GPSPolicy, error) {
+	switch GPSPolicy(name) {
+	case GPSPolicyKeep, GPSPolicyOverwrite, GPSPolicyIfFar:
+		return GPSPolicy(name), nil
 	}
 
-	// assume that the other GPS values are set if latitude is present
-	value, _ := gpsLatitude.([]exifcommon.Rational)
-	if gpsLatitude != nil && len(value) == 3 {
-		return operations, nil
+	return "", fmt.Errorf("unknown GPS policy %q, expected one of keep, overwrite or if-far", name)
+}
+
+// DefaultAltitudePrecision is the number of decimal places altitudes are written with
+const DefaultAltitudePrecision = 2
+
+// MaxAltitudePrecision is the most decimal places altitudes can be written with, more
+// would overflow the rational for high altitudes
+const MaxAltitudePrecision = 4
+
+// GPSOptions control how images' GPS data is updated
+type GPSOptions struct {
+	Policy GPSPolicy
+	// Threshold is the distance in meters used by GPSPolicyIfFar
+	Threshold float64
+	// AltitudePrecision is the number of decimal places altitudes are written with, up
+	// to MaxAltitudePrecision
+	AltitudePrecision int
+}
+
+// GPSAction is what was decided for an image's GPS data
+type GPSAction string
+
+const (
+	// GPSActionSet is used when the image had no valid GPS data
+	GPSActionSet GPSAction = "set"
+	// GPSActionReplace is used when existing GPS data is replaced
+	GPSActionReplace GPSAction = "replace"
+	// GPSActionKeep is used when existing GPS data is kept
+	GPSActionKeep GPSAction = "keep"
+)
+
+// GPSDecision describes what CheckGPSData decided to do with an image's GPS data
+type GPSDecision struct {
+	Action GPSAction
+	// Reason explains the decision
+	Reason string
+	// Distance is the distance in meters from the image's existing position to the GPX
+	// position, it's nil when the image had no valid position
+	Distance *float64
+}
+
+// CheckGPSData returns an operation to set the image's location from the GPX data when it is missing,
+// when the existing location is clearly bogus such as 0,0, or when the policy in opts says to replace
+// it. The decision made is returned along with the operations.
+// When there is no fix for the image's time, the returned error wraps a *gpx.NoFixError.
+func CheckGPSData(imageFile string, g *gpx.GPXDataset, opts GPSOptions) ([]Operation, GPSDecision, error) {
+	var operations []Operation
+	var decision GPSDecision
+
+	current, err := exif.GetGPS(imageFile)
+	if err != nil {
+		return operations, decision, fmt.Errorf("failed to get GPS data: %w", err)
 	}
 
 	// get the UTC time of the image
 	utcTime, err := exif.GetUTC(imageFile)
 	if err != nil {
-		return operations, fmt.Errorf("failed to determine UTC time for image: %w", err)
+		return operations, decision, fmt.Errorf("failed to determine UTC time for image: %w", err)
 	}
 
 	// find the point in the gpx dataset that matches the UTC time of the image
-	point, err := g.AtTime(utcTime)
+	match, err := g.Match(utcTime)
 	if err != nil {
-		return operations, fmt.Errorf("failed to find point at image UTC time: %w", err)
+		return operations, decision, fmt.Errorf("failed to find point at image UTC time: %w", err)
+	}
+	point := match.Point
+
+	decision = decideGPS(current, point.Latitude, point.Longitude, opts)
+	if decision.Action == GPSActionKeep {
+		return operations, decision, nil
 	}
 
 	// get the values from the point in the correct format to set in EXIF
@@ -51,26 +130,110 @@ func CheckGPSData(imageFile string, g *gpx.GPXDataset) ([]Operation, error) {
 		gpsLongitudeRef = "W"
 	}
 
-	altitude := dectofrac.NewRatP(point.Elevation.Value(), 0.0001)
-	altitudeRational := []exifcommon.Rational{
-		{
-			Numerator:   uint32(altitude.Num().Int64()),
-			Denominator: uint32(altitude.Denom().Int64()),
-		},
+	fields := map[string]interface{}{
+		"GPSLatitude":     gpsLatitudeRational,
+		"GPSLatitudeRef":  gpsLatitudeRef,
+		"GPSLongitude":    gpsLongitudeRational,
+		"GPSLongitudeRef": gpsLongitudeRef,
+		// the time of the GPX position, which is interpolated to the image's time
+		// unless the nearest point is used
+		"GPSTimeStamp":        exif.RationalHoursMinutesSecondsFromTime(point.Timestamp),
+		"GPSDateStamp":        point.Timestamp.UTC().Format("2006:01:02"),
+		"GPSVersionID":        []byte{2, 3, 0, 0},
+		"GPSMapDatum":         gpsMapDatum,
+		"GPSProcessingMethod": exif.CharacterCodeASCII(gpsProcessingMethod),
+	}
+
+	if point.Elevation.NotNull() {
+		altitude, altitudeRef, err := altitudeValues(point.Elevation.Value(), opts.AltitudePrecision)
+		if err != nil {
+			return operations, decision, err
+		}
+		fields["GPSAltitude"] = altitude
+		fields["GPSAltitudeRef"] = altitudeRef
+	} else if current != nil && current.Altitude != nil {
+		// the point has no elevation, so any existing altitude is removed rather than
+		// being left with the new position
+		fields["GPSAltitude"] = nil
+		fields["GPSAltitudeRef"] = nil
 	}
 
 	// set the values in the EXIF
 	operations = append(operations, Operation{
-		Reason:  "GPS data not found in EXIF",
-		IFDPath: "IFD/GPSInfo",
-		Fields: map[string]interface{}{
-			"GPSLatitude":     gpsLatitudeRational,
-			"GPSLatitudeRef":  gpsLatitudeRef,
-			"GPSLongitude":    gpsLongitudeRational,
-			"GPSLongitudeRef": gpsLongitudeRef,
-			"GPSAltitude":     altitudeRational,
-		},
+		Reason:        decision.Reason,
+		IFDPath:       "IFD/GPSInfo",
+		Fields:        fields,
+		Interpolation: match.Method,
 	})
 
-	return operations, nil
+	return operations, decision, nil
+}
+
+// altitudeValues returns the GPSAltitude and GPSAltitudeRef values for an elevation in
+// meters, rounded to precision decimal places. GPSAltitude can't be negative, so the ref
+// is set to 1 for elevations below sea level.
+func altitudeValues(elevation float64, precision int) ([]exifcommon.Rational, []byte, error) {
+	ref := []byte{0}
+	if elevation < 0 {
+		ref = []byte{1}
+	}
+
+	altitude, err := rationalFromDecimal(math.Abs(elevation), precision)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid elevation: %w", err)
+	}
+
+	return []exifcommon.Rational{altitude}, ref, nil
+}
+
+// rationalFromDecimal returns a positive value rounded to precision decimal places as a
+// rational. The fraction is reduced, so whole numbers are stored as n/1.
+func rationalFromDecimal(value float64, precision int) (exifcommon.Rational, error) {
+	scale := math.Pow10(precision)
+	scaled := math.Round(value * scale)
+	if math.IsNaN(scaled) || scaled < 0 || scaled > math.MaxUint32 {
+		return exifcommon.Rational{}, fmt.Errorf("%f can't be stored with %d decimal places", value, precision)
+	}
+
+	r := big.NewRat(int64(scaled), int64(scale))
+
+	return exifcommon.Rational{
+		Numerator:   uint32(r.Num().Int64()),
+		Denominator: uint32(r.Denom().Int64()),
+	}, nil
+}
+
+// decideGPS compares the image's current GPS data, which may be nil, with the GPX
+// position and decides whether to update it
+func decideGPS(current *exif.GPS, latitude, longitude float64, opts GPSOptions) GPSDecision {
+	if current == nil {
+		return GPSDecision{Action: GPSActionSet, Reason: "GPS data not found in EXIF"}
+	}
+
+	err := current.Validate()
+	if err != nil {
+		return GPSDecision{Action: GPSActionSet, Reason: fmt.Sprintf("GPS data in EXIF is invalid: %s", err)}
+	}
+
+	distance := gpxgo.HaversineDistance(current.Latitude, current.Longitude, latitude, longitude)
+	decision := GPSDecision{Distance: &distance}
+
+	switch opts.Policy {
+	case GPSPolicyOverwrite:
+		decision.Action = GPSActionReplace
+		decision.Reason = fmt.Sprintf("GPS data in EXIF overwritten, it was %.0fm from the GPX position", distance)
+	case GPSPolicyIfFar:
+		if distance > opts.Threshold {
+			decision.Action = GPSActionReplace
+			decision.Reason = fmt.Sprintf("GPS data in EXIF replaced, it was %.0fm from the GPX position, more than %.0fm", distance, opts.Threshold)
+		} else {
+			decision.Action = GPSActionKeep
+			decision.Reason = fmt.Sprintf("GPS data in EXIF kept, it is %.0fm from the GPX position, within %.0fm", distance, opts.Threshold)
+		}
+	default:
+		decision.Action = GPSActionKeep
+		decision.Reason = fmt.Sprintf("GPS data in EXIF kept, it is %.0fm from the GPX position", distance)
+	}
+
+	return decision
 }
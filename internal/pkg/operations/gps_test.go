@@ -14,7 +14,9 @@ func TestCheckGPSData(t *testing.T) {
 	testCases := map[string]struct {
 		Image      string
 		GPXFiles   []string
+		Options    GPSOptions
 		Operations []Operation
+		Action     GPSAction
 	}{
 		"when location is missing": {
 			Image:    "../exif/fixtures/x100f.jpg",
@@ -24,19 +26,87 @@ func TestCheckGPSData(t *testing.T) {
 					Reason:  "GPS data not found in EXIF",
 					IFDPath: "IFD/GPSInfo",
 					Fields: map[string]interface{}{
-						"GPSLatitude":     exif.RationalDegreesMinutesSecondsFromDecimal(51.56734),
-						"GPSLatitudeRef":  "N",
-						"GPSLongitude":    exif.RationalDegreesMinutesSecondsFromDecimal(-0.13843),
-						"GPSLongitudeRef": "W",
-						"GPSAltitude":     []exifcommon.Rational{{Numerator: 75, Denominator: 1}},
+						"GPSLatitude":         exif.RationalDegreesMinutesSecondsFromDecimal(51.56734),
+						"GPSLatitudeRef":      "N",
+						"GPSLongitude":        exif.RationalDegreesMinutesSecondsFromDecimal(-0.13843),
+						"GPSLongitudeRef":     "W",
+						"GPSAltitude":         []exifcommon.Rational{{Numerator: 75, Denominator: 1}},
+						"GPSAltitudeRef":      []byte{0},
+						"GPSTimeStamp":        []exifcommon.Rational{{Numerator: 23, Denominator: 1}, {Numerator: 18, Denominator: 1}, {Numerator: 7, Denominator: 1}},
+						"GPSDateStamp":        "2022:08:03",
+						"GPSVersionID":        []byte{2, 3, 0, 0},
+						"GPSMapDatum":         "WGS-84",
+						"GPSProcessingMethod": exif.CharacterCodeASCII("GPS"),
 					},
+					Interpolation: gpx.InterpolationNearest,
 				},
 			},
+			Action: GPSActionSet,
 		},
 		"when location is already set": {
 			Image:      "../exif/fixtures/iphone.JPG",
 			GPXFiles:   []string{"./fixtures/2022-08-03.gpx"},
 			Operations: nil,
+			Action:     GPSActionKeep,
+		},
+		"when location is within the if-far threshold": {
+			Image:      "../exif/fixtures/iphone.JPG",
+			GPXFiles:   []string{"./fixtures/2022-08-03.gpx"},
+			Options:    GPSOptions{Policy: GPSPolicyIfFar, Threshold: 1000},
+			Operations: nil,
+			Action:     GPSActionKeep,
+		},
+		"when location is already set and overwritten": {
+			Image:    "../exif/fixtures/iphone.JPG",
+			GPXFiles: []string{"./fixtures/2022-08-03.gpx"},
+			Options:  GPSOptions{Policy: GPSPolicyOverwrite},
+			Operations: []Operation{
+				{
+					Reason:  "GPS data in EXIF overwritten, it was 20m from the GPX position",
+					IFDPath: "IFD/GPSInfo",
+					Fields: map[string]interface{}{
+						"GPSLatitude":         exif.RationalDegreesMinutesSecondsFromDecimal(51.56734),
+						"GPSLatitudeRef":      "N",
+						"GPSLongitude":        exif.RationalDegreesMinutesSecondsFromDecimal(-0.13843),
+						"GPSLongitudeRef":     "W",
+						"GPSAltitude":         []exifcommon.Rational{{Numerator: 75, Denominator: 1}},
+						"GPSAltitudeRef":      []byte{0},
+						"GPSTimeStamp":        []exifcommon.Rational{{Numerator: 23, Denominator: 1}, {Numerator: 18, Denominator: 1}, {Numerator: 7, Denominator: 1}},
+						"GPSDateStamp":        "2022:08:03",
+						"GPSVersionID":        []byte{2, 3, 0, 0},
+						"GPSMapDatum":         "WGS-84",
+						"GPSProcessingMethod": exif.CharacterCodeASCII("GPS"),
+					},
+					Interpolation: gpx.InterpolationNearest,
+				},
+			},
+			Action: GPSActionReplace,
+		},
+		"when overwriting with a point without elevation": {
+			Image:    "../exif/fixtures/iphone.JPG",
+			GPXFiles: []string{"./fixtures/2022-08-03-no-elevation.gpx"},
+			Options:  GPSOptions{Policy: GPSPolicyOverwrite},
+			Operations: []Operation{
+				{
+					Reason:  "GPS data in EXIF overwritten, it was 20m from the GPX position",
+					IFDPath: "IFD/GPSInfo",
+					Fields: map[string]interface{}{
+						"GPSLatitude":         exif.RationalDegreesMinutesSecondsFromDecimal(51.56734),
+						"GPSLatitudeRef":      "N",
+						"GPSLongitude":        exif.RationalDegreesMinutesSecondsFromDecimal(-0.13843),
+						"GPSLongitudeRef":     "W",
+						"GPSAltitude":         nil,
+						"GPSAltitudeRef":      nil,
+						"GPSTimeStamp":        []exifcommon.Rational{{Numerator: 23, Denominator: 1}, {Numerator: 18, Denominator: 1}, {Numerator: 7, Denominator: 1}},
+						"GPSDateStamp":        "2022:08:03",
+						"GPSVersionID":        []byte{2, 3, 0, 0},
+						"GPSMapDatum":         "WGS-84",
+						"GPSProcessingMethod": exif.CharacterCodeASCII("GPS"),
+					},
+					Interpolation: gpx.InterpolationNearest,
+				},
+			},
+			Action: GPSActionReplace,
 		},
 	}
 
@@ -45,10 +115,153 @@ func TestCheckGPSData(t *testing.T) {
 			g, err := gpx.NewGPXDatasetFromDisk(testCase.GPXFiles...)
 			require.NoError(t, err)
 
-			operations, err := CheckGPSData(testCase.Image, &g)
+			operations, decision, err := CheckGPSData(testCase.Image, &g, testCase.Options)
 			require.NoError(t, err)
 
 			assert.Equal(t, testCase.Operations, operations)
+			assert.Equal(t, testCase.Action, decision.Action)
 		})
 	}
 }
+
+func TestDecideGPS(t *testing.T) {
+	// about 111m north of the GPX position
+	near := &exif.GPS{Latitude: 51.001, Longitude: -0.1}
+	// about 1.1km north of the GPX position
+	far := &exif.GPS{Latitude: 51.01, Longitude: -0.1}
+
+	testCases := map[string]struct {
+		Current          *exif.GPS
+		Options          GPSOptions
+		ExpectedAction   GPSAction
+		ExpectedReason   string
+		ExpectedDistance float64
+	}{
+		"missing": {
+			Current:        nil,
+			Options:        GPSOptions{Policy: GPSPolicyKeep},
+			ExpectedAction: GPSActionSet,
+			ExpectedReason: "GPS data not found in EXIF",
+		},
+		"invalid": {
+			Current:        &exif.GPS{},
+			Options:        GPSOptions{Policy: GPSPolicyKeep},
+			ExpectedAction: GPSActionSet,
+			ExpectedReason: "GPS data in EXIF is invalid: position is 0,0",
+		},
+		"keep": {
+			Current:          far,
+			Options:          GPSOptions{Policy: GPSPolicyKeep},
+			ExpectedAction:   GPSActionKeep,
+			ExpectedReason:   "GPS data in EXIF kept, it is 1112m from the GPX position",
+			ExpectedDistance: 1112,
+		},
+		"overwrite": {
+			Current:          near,
+			Options:          GPSOptions{Policy: GPSPolicyOverwrite},
+			ExpectedAction:   GPSActionReplace,
+			ExpectedReason:   "GPS data in EXIF overwritten, it was 111m from the GPX position",
+			ExpectedDistance: 111,
+		},
+		"if far and near": {
+			Current:          near,
+			Options:          GPSOptions{Policy: GPSPolicyIfFar, Threshold: 500},
+			ExpectedAction:   GPSActionKeep,
+			ExpectedReason:   "GPS data in EXIF kept, it is 111m from the GPX position, within 500m",
+			ExpectedDistance: 111,
+		},
+		"if far and far": {
+			Current:          far,
+			Options:          GPSOptions{Policy: GPSPolicyIfFar, Threshold: 500},
+			ExpectedAction:   GPSActionReplace,
+			ExpectedReason:   "GPS data in EXIF replaced, it was 1112m from the GPX position, more than 500m",
+			ExpectedDistance: 1112,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			decision := decideGPS(testCase.Current, 51, -0.1, testCase.Options)
+
+			assert.Equal(t, testCase.ExpectedAction, decision.Action)
+			assert.Equal(t, testCase.ExpectedReason, decision.Reason)
+			if testCase.ExpectedDistance == 0 {
+				assert.Nil(t, decision.Distance)
+				return
+			}
+			require.NotNil(t, decision.Distance)
+			assert.InDelta(t, testCase.ExpectedDistance, *decision.Distance, 1)
+		})
+	}
+}
+
+func TestAltitudeValues(t *testing.T) {
+	testCases := map[string]struct {
+		Elevation     float64
+		Precision     int
+		Expected      []exifcommon.Rational
+		ExpectedRef   []byte
+		ExpectedError bool
+	}{
+		"whole meters": {
+			Elevation:   75,
+			Precision:   2,
+			Expected:    []exifcommon.Rational{{Numerator: 75, Denominator: 1}},
+			ExpectedRef: []byte{0},
+		},
+		"rounded": {
+			Elevation:   74.5478,
+			Precision:   2,
+			Expected:    []exifcommon.Rational{{Numerator: 1491, Denominator: 20}},
+			ExpectedRef: []byte{0},
+		},
+		"reduced": {
+			Elevation:   0.5,
+			Precision:   2,
+			Expected:    []exifcommon.Rational{{Numerator: 1, Denominator: 2}},
+			ExpectedRef: []byte{0},
+		},
+		"no decimal places": {
+			Elevation:   74.5478,
+			Precision:   0,
+			Expected:    []exifcommon.Rational{{Numerator: 75, Denominator: 1}},
+			ExpectedRef: []byte{0},
+		},
+		"below sea level": {
+			Elevation:   -430.55,
+			Precision:   2,
+			Expected:    []exifcommon.Rational{{Numerator: 8611, Denominator: 20}},
+			ExpectedRef: []byte{1},
+		},
+		"too precise": {
+			Elevation:     8848.86,
+			Precision:     6,
+			ExpectedError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			altitude, ref, err := altitudeValues(testCase.Elevation, testCase.Precision)
+			if testCase.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Expected, altitude)
+			assert.Equal(t, testCase.ExpectedRef, ref)
+		})
+	}
+}
+
+func TestParseGPSPolicy(t *testing.T) {
+	for _, name := range []string{"keep", "overwrite", "if-far"} {
+		policy, err := ParseGPSPolicy(name)
+		require.NoError(t, err)
+		assert.Equal(t, GPSPolicy(name), policy)
+	}
+
+	_, err := ParseGPSPolicy("sometimes")
+	assert.Error(t, err)
+}
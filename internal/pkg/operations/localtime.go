@@ -8,6 +8,16 @@ import (
 	"time"
 )
 
+// TimezoneAt returns the timezone used for local times at a position
+func TimezoneAt(latitude, longitude float64) (*time.Location, error) {
+	location, err := time.LoadLocation(timezonemapper.LatLngToTimezoneString(latitude, longitude))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse location from GPS point: %s", err)
+	}
+
+	return location, nil
+}
+
 func CheckLocalTime(imageFile string, g *gpx.GPXDataset) ([]Operation, error) {
 	var operations []Operation
 
@@ -15,19 +25,19 @@ func CheckLocalTime(imageFile string, g *gpx.GPXDataset) ([]Operation, error) {
 	// if no offset is set, then the time is assumed to be UTC
 	utcTime, err := exif.GetUTC(imageFile)
 	if err != nil {
-		return operations, fmt.Errorf("failed to get UTC time for image: %s", err)
+		return operations, fmt.Errorf("failed to get UTC time for image: %w", err)
 	}
 
 	// find the nearest point from the GPX track for that UTC time
 	p, err := g.AtTime(utcTime)
 	if err != nil {
-		return operations, fmt.Errorf("failed to get point for time: %s\n\n", err)
+		return operations, fmt.Errorf("failed to get point for time: %w", err)
 	}
 
 	// calculate the local time for the image from the UTC time and the GPS location
-	location, err := time.LoadLocation(timezonemapper.LatLngToTimezoneString(p.Latitude, p.Longitude))
+	location, err := TimezoneAt(p.Latitude, p.Longitude)
 	if err != nil {
-		return operations, fmt.Errorf("failed to parse location from GPS point: %s", err)
+		return operations, err
 	}
 	local := utcTime
 	local = local.In(location)
@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 
 	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
 )
@@ -48,3 +49,44 @@ func TestCheckLocalTime(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckLocalTimeNoFix(t *testing.T) {
+	g, err := gpx.NewGPXDatasetFromDisk("../gpx/fixtures/run.gpx")
+	require.NoError(t, err)
+	g.MaxGap = time.Hour
+
+	_, err = CheckLocalTime("../exif/fixtures/iphone.JPG", &g)
+
+	var noFixErr *gpx.NoFixError
+	require.ErrorAs(t, err, &noFixErr)
+	assert.Equal(t, time.Hour, noFixErr.MaxGap)
+	assert.True(t, noFixErr.OutOfRange)
+}
+
+func TestTimezoneAt(t *testing.T) {
+	testCases := map[string]struct {
+		Latitude  float64
+		Longitude float64
+		Expected  string
+	}{
+		"london": {
+			Latitude:  51.56734,
+			Longitude: -0.13843,
+			Expected:  "Europe/London",
+		},
+		"tokyo": {
+			Latitude:  35.6762,
+			Longitude: 139.6503,
+			Expected:  "Asia/Tokyo",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			location, err := TimezoneAt(testCase.Latitude, testCase.Longitude)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Expected, location.String())
+		})
+	}
+}
@@ -11,7 +11,7 @@ func CheckModTime(imageFile string) ([]Operation, error) {
 
 	utcTime, err := exif.GetUTC(imageFile)
 	if err != nil {
-		return operations, fmt.Errorf("failed to get UTC time for image: %s", err)
+		return operations, fmt.Errorf("failed to get UTC time for image: %w", err)
 	}
 
 	t, err := times.Stat(imageFile)
@@ -0,0 +1,97 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// DefaultMinSpeed is the speed in km/h below which no motion is written
+const DefaultMinSpeed = 2.0
+
+// MotionOptions control how the direction and speed of travel are written
+type MotionOptions struct {
+	// MinSpeed is the speed in km/h below which the device is treated as standing still
+	// and no motion is written, since the course between close points is mostly noise
+	MinSpeed float64
+	// ImgDirection also sets GPSImgDirection to the course, this assumes that the camera
+	// was pointed in the direction of travel
+	ImgDirection bool
+}
+
+// CheckMotion returns an operation to set the course and speed of travel at the time the
+// image was taken, from the GPX points either side of it. No operation is returned when
+// the GPX data has no points around the time or the speed is below the minimum.
+func CheckMotion(imageFile string, g *gpx.GPXDataset, opts MotionOptions) ([]Operation, error) {
+	var operations []Operation
+
+	utcTime, err := exif.GetUTC(imageFile)
+	if err != nil {
+		return operations, fmt.Errorf("failed to determine UTC time for image: %w", err)
+	}
+
+	motion, err := g.Motion(utcTime)
+	if errors.Is(err, gpx.ErrNoMotion) {
+		return operations, nil
+	}
+	if err != nil {
+		return operations, fmt.Errorf("failed to find motion at image UTC time: %w", err)
+	}
+
+	// GPSSpeed is written in km/h
+	speed := motion.Speed * 3.6
+	if speed < opts.MinSpeed {
+		return operations, nil
+	}
+
+	fields, err := motionFields(motion.Course, speed, opts.ImgDirection)
+	if err != nil {
+		return operations, err
+	}
+
+	operations = append(operations, Operation{
+		Reason:  fmt.Sprintf("Motion from GPX track: %.1f km/h towards %.0f°", speed, motion.Course),
+		IFDPath: "IFD/GPSInfo",
+		Fields:  fields,
+	})
+
+	return operations, nil
+}
+
+// motionFields returns the GPS fields for a course in degrees from true north and a
+// speed in km/h
+func motionFields(course, speed float64, imgDirection bool) (map[string]interface{}, error) {
+	// a course which rounds up to 360 is written as due north
+	course = math.Round(course*100) / 100
+	if course >= 360 {
+		course = 0
+	}
+
+	courseRational, err := rationalFromDecimal(course, 2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid course: %w", err)
+	}
+	speedRational, err := rationalFromDecimal(speed, 2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid speed: %w", err)
+	}
+
+	fields := map[string]interface{}{
+		// T is true north, rather than magnetic north
+		"GPSTrackRef": "T",
+		"GPSTrack":    []exifcommon.Rational{courseRational},
+		// K is km/h
+		"GPSSpeedRef": "K",
+		"GPSSpeed":    []exifcommon.Rational{speedRational},
+	}
+	if imgDirection {
+		fields["GPSImgDirectionRef"] = "T"
+		fields["GPSImgDirection"] = []exifcommon.Rational{courseRational}
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,91 @@
+package operations
+
+import (
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
+)
+
+func TestCheckMotion(t *testing.T) {
+	testCases := map[string]struct {
+		Image      string
+		GPXFiles   []string
+		Options    MotionOptions
+		Operations []Operation
+	}{
+		"when moving": {
+			Image:    "../exif/fixtures/iphone.JPG",
+			GPXFiles: []string{"./fixtures/2022-08-03-moving.gpx"},
+			Options:  MotionOptions{MinSpeed: DefaultMinSpeed},
+			Operations: []Operation{
+				{
+					Reason:  "Motion from GPX track: 4.1 km/h towards 90°",
+					IFDPath: "IFD/GPSInfo",
+					Fields: map[string]interface{}{
+						"GPSTrackRef": "T",
+						"GPSTrack":    []exifcommon.Rational{{Numerator: 90, Denominator: 1}},
+						"GPSSpeedRef": "K",
+						"GPSSpeed":    []exifcommon.Rational{{Numerator: 83, Denominator: 20}},
+					},
+				},
+			},
+		},
+		"when moving with image direction": {
+			Image:    "../exif/fixtures/iphone.JPG",
+			GPXFiles: []string{"./fixtures/2022-08-03-moving.gpx"},
+			Options:  MotionOptions{MinSpeed: DefaultMinSpeed, ImgDirection: true},
+			Operations: []Operation{
+				{
+					Reason:  "Motion from GPX track: 4.1 km/h towards 90°",
+					IFDPath: "IFD/GPSInfo",
+					Fields: map[string]interface{}{
+						"GPSTrackRef":        "T",
+						"GPSTrack":           []exifcommon.Rational{{Numerator: 90, Denominator: 1}},
+						"GPSSpeedRef":        "K",
+						"GPSSpeed":           []exifcommon.Rational{{Numerator: 83, Denominator: 20}},
+						"GPSImgDirectionRef": "T",
+						"GPSImgDirection":    []exifcommon.Rational{{Numerator: 90, Denominator: 1}},
+					},
+				},
+			},
+		},
+		"when slower than the minimum speed": {
+			Image:      "../exif/fixtures/iphone.JPG",
+			GPXFiles:   []string{"./fixtures/2022-08-03-moving.gpx"},
+			Options:    MotionOptions{MinSpeed: 5},
+			Operations: nil,
+		},
+		"when there are no points either side": {
+			Image:      "../exif/fixtures/iphone.JPG",
+			GPXFiles:   []string{"./fixtures/2022-08-03.gpx"},
+			Options:    MotionOptions{MinSpeed: DefaultMinSpeed},
+			Operations: nil,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g, err := gpx.NewGPXDatasetFromDisk(testCase.GPXFiles...)
+			require.NoError(t, err)
+
+			operations, err := CheckMotion(testCase.Image, &g, testCase.Options)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Operations, operations)
+		})
+	}
+}
+
+func TestMotionFields(t *testing.T) {
+	fields, err := motionFields(359.999, 12.345, false)
+	require.NoError(t, err)
+
+	// courses which round up to 360 are due north
+	assert.Equal(t, []exifcommon.Rational{{Numerator: 0, Denominator: 1}}, fields["GPSTrack"])
+	assert.Equal(t, []exifcommon.Rational{{Numerator: 247, Denominator: 20}}, fields["GPSSpeed"])
+	assert.NotContains(t, fields, "GPSImgDirection")
+}
@@ -3,7 +3,9 @@ package operations
 import (
 	"fmt"
 	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
 	"os"
+	"sort"
 )
 
 // Operation describes a set of related changes to an image.
@@ -14,14 +16,60 @@ type Operation struct {
 	IFDPath string
 	// Fields is the desired state of some EXIF fields
 	Fields map[string]interface{}
+	// Interpolation is the method used to derive a position from the GPX data when
+	// the operation sets location fields
+	Interpolation gpx.Interpolation
 
 	// ModTime if set will trigger the operation exec to update the mtime of the
 	// file to the DateTimeOriginal of the image.
 	ModTime bool
 }
 
+// Changes returns the EXIF changes for the operation's fields, ordered by key
+func (o *Operation) Changes() []exif.Change {
+	keys := make([]string, 0, len(o.Fields))
+	for k := range o.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	changes := make([]exif.Change, 0, len(keys))
+	for _, k := range keys {
+		changes = append(changes, exif.Change{IFDPath: o.IFDPath, Key: k, Value: o.Fields[k]})
+	}
+
+	return changes
+}
+
+// Changes returns the EXIF changes for all the operations in order
+func Changes(operations []Operation) []exif.Change {
+	var changes []exif.Change
+	for _, o := range operations {
+		changes = append(changes, o.Changes()...)
+	}
+
+	return changes
+}
+
 func (o *Operation) Execute(image string) error {
-	if o.ModTime {
+	return ExecuteAll(image, []Operation{*o})
+}
+
+// ExecuteAll runs a set of operations on an image. The EXIF changes from all the
+// operations are written to the image at once, mtime updates are made afterwards
+// since they depend on the updated EXIF data.
+func ExecuteAll(image string, operations []Operation) error {
+	modTime := false
+	for _, o := range operations {
+		modTime = modTime || o.ModTime
+	}
+
+	err := exif.Apply(image, Changes(operations))
+	if err != nil {
+		return fmt.Errorf("failed to update exif data: %s", err)
+	}
+
+	if modTime {
 		utcTime, err := exif.GetUTC(image)
 		if err != nil {
 			return fmt.Errorf("failed to get utc time: %w", err)
@@ -31,15 +79,6 @@ func (o *Operation) Execute(image string) error {
 		if err != nil {
 			return fmt.Errorf("failed to set image mtime from utc DateTimeOriginal value: %w", err)
 		}
-		return nil
-	}
-
-	// otherwise, update the exif data
-	for k, v := range o.Fields {
-		err := exif.SetKey(image, o.IFDPath, k, v)
-		if err != nil {
-			return fmt.Errorf("failed to set %v to %v: %s", k, v, err)
-		}
 	}
 
 	return nil
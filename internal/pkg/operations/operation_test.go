@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestOperationExecute(t *testing.T) {
@@ -76,3 +77,74 @@ func TestOperationExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteAll(t *testing.T) {
+	imageCopy, err := ioutil.TempFile(".", "image_")
+	require.NoError(t, err)
+	defer os.Remove(imageCopy.Name())
+
+	imageFile, err := os.Open("../exif/fixtures/iphone.JPG")
+	require.NoError(t, err)
+	defer imageFile.Close()
+
+	_, err = io.Copy(imageCopy, imageFile)
+	require.NoError(t, err)
+
+	operations := []Operation{
+		{
+			Reason:  "GPS data not found in EXIF",
+			IFDPath: "IFD/GPSInfo",
+			Fields: map[string]interface{}{
+				"GPSLatitude":     exif.RationalDegreesMinutesSecondsFromDecimal(51.56734),
+				"GPSLatitudeRef":  "N",
+				"GPSLongitude":    exif.RationalDegreesMinutesSecondsFromDecimal(-0.13843),
+				"GPSLongitudeRef": "W",
+			},
+		},
+		{
+			Reason:  "DateTimeOriginal data was not in local time",
+			IFDPath: "IFD/Exif",
+			Fields: map[string]interface{}{
+				"DateTimeOriginal":   "2022:08:03 17:56:22",
+				"OffsetTimeOriginal": "+00:00",
+			},
+		},
+		{
+			Reason:  "mtime != utc time",
+			ModTime: true,
+		},
+	}
+
+	err = ExecuteAll(imageCopy.Name(), operations)
+	require.NoError(t, err)
+
+	for _, op := range operations {
+		for _, c := range op.Changes() {
+			value, err := exif.GetKey(imageCopy.Name(), c.IFDPath, c.Key)
+			require.NoError(t, err)
+			assert.Equal(t, c.Value, value)
+		}
+	}
+
+	// the mtime is set from the updated exif data
+	mtime, err := times.Stat(imageCopy.Name())
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2022, time.August, 3, 17, 56, 22, 480000000, time.UTC), mtime.ModTime().UTC())
+}
+
+func TestOperationChanges(t *testing.T) {
+	op := Operation{
+		IFDPath: "IFD/Exif",
+		Fields: map[string]interface{}{
+			"SubSecTimeOriginal": "0",
+			"DateTimeOriginal":   "2022:08:03 18:57:55",
+			"OffsetTimeOriginal": "+01:00",
+		},
+	}
+
+	assert.Equal(t, []exif.Change{
+		{IFDPath: "IFD/Exif", Key: "DateTimeOriginal", Value: "2022:08:03 18:57:55"},
+		{IFDPath: "IFD/Exif", Key: "OffsetTimeOriginal", Value: "+01:00"},
+		{IFDPath: "IFD/Exif", Key: "SubSecTimeOriginal", Value: "0"},
+	}, op.Changes())
+}
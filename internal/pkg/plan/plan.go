@@ -0,0 +1,182 @@
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
+	"github.com/charlieegan3/gpxif/internal/pkg/operations"
+	"github.com/charlieegan3/gpxif/internal/pkg/utils"
+)
+
+// ErrDrift is returned when an image has changed since its plan was made
+var ErrDrift = errors.New("image has changed since the plan was made")
+
+// Plan is the set of operations to run on a set of images. Plans are written as
+// JSON, or YAML when the file has a .yaml or .yml extension.
+type Plan struct {
+	Images []Image `json:"images" yaml:"images"`
+}
+
+// Image is the planned operations for a single image
+type Image struct {
+	// Path is the image file, relative to the plan file when it's written
+	Path string `json:"path" yaml:"path"`
+	// Hash is the SHA-256 of the image's content when the plan was made
+	Hash string `json:"hash" yaml:"hash"`
+	// Operations are run in order
+	Operations []Operation `json:"operations" yaml:"operations"`
+}
+
+// Operation is the serialized form of an operations.Operation
+type Operation struct {
+	Reason        string                `json:"reason" yaml:"reason"`
+	IFDPath       string                `json:"ifd_path,omitempty" yaml:"ifd_path,omitempty"`
+	Fields        map[string]exif.Value `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Interpolation gpx.Interpolation     `json:"interpolation,omitempty" yaml:"interpolation,omitempty"`
+	ModTime       bool                  `json:"mod_time,omitempty" yaml:"mod_time,omitempty"`
+}
+
+// NewImage returns the plan entry for running operations on image
+func NewImage(image string, ops []operations.Operation) (Image, error) {
+	hash, err := utils.HashFile(image)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to hash image: %w", err)
+	}
+
+	i := Image{Path: image, Hash: hash}
+	for _, o := range ops {
+		op := Operation{
+			Reason:        o.Reason,
+			IFDPath:       o.IFDPath,
+			Interpolation: o.Interpolation,
+			ModTime:       o.ModTime,
+		}
+		if len(o.Fields) > 0 {
+			op.Fields = make(map[string]exif.Value, len(o.Fields))
+			for k, v := range o.Fields {
+				op.Fields[k] = exif.Value{V: v}
+			}
+		}
+		i.Operations = append(i.Operations, op)
+	}
+
+	return i, nil
+}
+
+// Planned returns the operations to run on the image
+func (i *Image) Planned() []operations.Operation {
+	ops := make([]operations.Operation, 0, len(i.Operations))
+	for _, o := range i.Operations {
+		op := operations.Operation{
+			Reason:        o.Reason,
+			IFDPath:       o.IFDPath,
+			Interpolation: o.Interpolation,
+			ModTime:       o.ModTime,
+		}
+		if len(o.Fields) > 0 {
+			op.Fields = make(map[string]interface{}, len(o.Fields))
+			for k, v := range o.Fields {
+				op.Fields[k] = v.V
+			}
+		}
+		ops = append(ops, op)
+	}
+
+	return ops
+}
+
+// Check returns ErrDrift if the image's content differs from when the plan was made
+func (i *Image) Check() error {
+	hash, err := utils.HashFile(i.Path)
+	if err != nil {
+		return fmt.Errorf("failed to hash image: %w", err)
+	}
+	if hash != i.Hash {
+		return ErrDrift
+	}
+
+	return nil
+}
+
+// Write saves the plan to path. Image paths are stored relative to the plan file so
+// that the plan can be kept alongside the images.
+func Write(path string, p Plan) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for plan: %w", err)
+	}
+
+	out := Plan{Images: make([]Image, len(p.Images))}
+	for i, image := range p.Images {
+		out.Images[i] = image
+
+		abs, err := filepath.Abs(image.Path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for image: %w", err)
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			rel = abs
+		}
+		out.Images[i].Path = filepath.ToSlash(rel)
+	}
+
+	var b []byte
+	if isYAML(path) {
+		b, err = yaml.Marshal(out)
+	} else {
+		b, err = json.MarshalIndent(out, "", "  ")
+		b = append(b, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+
+	return utils.WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// Load reads a plan from path, image paths are resolved relative to the plan file
+func Load(path string) (Plan, error) {
+	var p Plan
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	if isYAML(path) {
+		err = yaml.Unmarshal(b, &p)
+	} else {
+		err = json.Unmarshal(b, &p)
+	}
+	if err != nil {
+		return p, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	for i, image := range p.Images {
+		imagePath := filepath.FromSlash(image.Path)
+		if !filepath.IsAbs(imagePath) {
+			imagePath = filepath.Join(filepath.Dir(path), imagePath)
+		}
+		p.Images[i].Path = imagePath
+	}
+
+	return p, nil
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
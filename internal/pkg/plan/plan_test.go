@@ -0,0 +1,107 @@
+package plan
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/gpx"
+	"github.com/charlieegan3/gpxif/internal/pkg/operations"
+)
+
+func TestWriteLoad(t *testing.T) {
+	ops := []operations.Operation{
+		{
+			Reason:  "GPS data missing",
+			IFDPath: "IFD/GPSInfo",
+			Fields: map[string]interface{}{
+				"GPSLatitudeRef": "N",
+				"GPSLatitude": []exifcommon.Rational{
+					{Numerator: 51, Denominator: 1},
+					{Numerator: 34, Denominator: 1},
+					{Numerator: 251, Denominator: 100},
+				},
+			},
+			Interpolation: gpx.InterpolationLinear,
+		},
+		{
+			Reason:  "mtime does not match",
+			ModTime: true,
+		},
+	}
+
+	testCases := map[string]struct {
+		PlanFile string
+	}{
+		"json": {PlanFile: "plan.json"},
+		"yaml": {PlanFile: "plan.yaml"},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			image := copyImage(t, "../exif/fixtures/iphone.JPG", filepath.Join(dir, "images"))
+
+			i, err := NewImage(image, ops)
+			require.NoError(t, err)
+
+			planPath := filepath.Join(dir, testCase.PlanFile)
+			err = Write(planPath, Plan{Images: []Image{i}})
+			require.NoError(t, err)
+
+			raw, err := os.ReadFile(planPath)
+			require.NoError(t, err)
+			assert.Contains(t, string(raw), "images/iphone.JPG")
+
+			p, err := Load(planPath)
+			require.NoError(t, err)
+			require.Len(t, p.Images, 1)
+
+			assert.Equal(t, image, p.Images[0].Path)
+			assert.Equal(t, i.Hash, p.Images[0].Hash)
+			assert.Equal(t, ops, p.Images[0].Planned())
+			assert.NoError(t, p.Images[0].Check())
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	image := copyImage(t, "../exif/fixtures/iphone.JPG", t.TempDir())
+
+	i, err := NewImage(image, nil)
+	require.NoError(t, err)
+	require.NoError(t, i.Check())
+
+	f, err := os.OpenFile(image, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.ErrorIs(t, i.Check(), ErrDrift)
+}
+
+func copyImage(t *testing.T, source, dir string) string {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	dst := filepath.Join(dir, filepath.Base(source))
+
+	in, err := os.Open(source)
+	require.NoError(t, err)
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	require.NoError(t, err)
+
+	return dst
+}
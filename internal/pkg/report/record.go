@@ -0,0 +1,152 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+)
+
+// Format is how results are written
+type Format string
+
+const (
+	// FormatText writes a description of each image for people to read
+	FormatText Format = "text"
+	// FormatJSON writes a JSON array with a Record for each image once all are processed
+	FormatJSON Format = "json"
+	// FormatNDJSON writes a Record for each image on its own line as it's processed
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the Format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatText, FormatJSON, FormatNDJSON:
+		return Format(name), nil
+	}
+
+	return "", fmt.Errorf("unknown output format %q, expected one of text, json or ndjson", name)
+}
+
+// OperationStatus is the state of an operation on an image
+type OperationStatus string
+
+const (
+	// StatusPlanned is used for operations which were not run, e.g. for dry runs
+	StatusPlanned OperationStatus = "planned"
+	// StatusApplied is used for operations which were run
+	StatusApplied OperationStatus = "applied"
+	// StatusFailed is used for operations which failed to run
+	StatusFailed OperationStatus = "failed"
+)
+
+// Record describes the processing of a single image
+type Record struct {
+	Path    string  `json:"path"`
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+	// UTCTime is the time the image was taken, derived from its EXIF data
+	UTCTime *time.Time `json:"utc_time,omitempty"`
+	// Match is the point from the GPX data for UTCTime
+	Match *Match `json:"match,omitempty"`
+	// Distance is the distance in meters from the image's existing location to the
+	// matched point that the GPS decision was based on, it's only set when the image has
+	// a valid location
+	Distance *float64 `json:"distance,omitempty"`
+	// GPS is what was decided for the image's GPS data
+	GPS        *GPSDecision `json:"gps,omitempty"`
+	Operations []Operation  `json:"operations"`
+}
+
+// GPSDecision is what was decided for an image's GPS data, the distance it's based on
+// is the record's Distance
+type GPSDecision struct {
+	// Action is set, replace or keep
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// Match is a point from the GPX data matched to an image
+type Match struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Elevation *float64 `json:"elevation,omitempty"`
+	// Method is the interpolation used to find the point
+	Method string `json:"method"`
+	// NearestTime is the time of the recorded point closest to the image's time
+	NearestTime time.Time `json:"nearest_time"`
+	// TimeDelta is the number of seconds from NearestTime to the image's time
+	TimeDelta float64 `json:"time_delta"`
+}
+
+// Operation is an operation on an image and the changes it makes
+type Operation struct {
+	Reason  string          `json:"reason"`
+	Status  OperationStatus `json:"status"`
+	ModTime bool            `json:"mod_time,omitempty"`
+	Changes []Change        `json:"changes"`
+}
+
+// Change is a change to an EXIF value, Old is null when the value was not set
+type Change struct {
+	IFDPath string     `json:"ifd_path"`
+	Key     string     `json:"key"`
+	Old     exif.Value `json:"old"`
+	New     exif.Value `json:"new"`
+}
+
+// RecordWriter writes records in the JSON formats
+type RecordWriter struct {
+	w       io.Writer
+	format  Format
+	records []Record
+}
+
+// NewRecordWriter returns a writer for records in format, which must be FormatJSON
+// or FormatNDJSON
+func NewRecordWriter(w io.Writer, format Format) *RecordWriter {
+	return &RecordWriter{w: w, format: format}
+}
+
+// Write writes the record, or holds it until Close for FormatJSON
+func (rw *RecordWriter) Write(r Record) error {
+	if r.Operations == nil {
+		r.Operations = []Operation{}
+	}
+
+	if rw.format == FormatJSON {
+		rw.records = append(rw.records, r)
+		return nil
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	_, err = rw.w.Write(append(b, '\n'))
+	return err
+}
+
+// Close writes any held records
+func (rw *RecordWriter) Close() error {
+	if rw.format != FormatJSON {
+		return nil
+	}
+
+	records := rw.records
+	if records == nil {
+		records = []Record{}
+	}
+
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	_, err = rw.w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/gpxif/internal/pkg/exif"
+)
+
+func TestRecordWriter(t *testing.T) {
+	utcTime := time.Date(2022, time.August, 3, 17, 56, 22, 0, time.UTC)
+	elevation := 75.0
+
+	records := []Record{
+		{
+			Path:    "a.jpg",
+			Outcome: OutcomeTagged,
+			UTCTime: &utcTime,
+			Match: &Match{
+				Latitude:    51.5,
+				Longitude:   -0.1,
+				Elevation:   &elevation,
+				Method:      "nearest",
+				NearestTime: utcTime.Add(-2 * time.Second),
+				TimeDelta:   2,
+			},
+			Operations: []Operation{
+				{
+					Reason: "GPS data not found in EXIF",
+					Status: StatusApplied,
+					Changes: []Change{
+						{IFDPath: "IFD/GPSInfo", Key: "GPSLatitudeRef", Old: exif.Value{}, New: exif.Value{V: "N"}},
+					},
+				},
+			},
+		},
+		{
+			Path:    "b.txt",
+			Outcome: OutcomeUnsupported,
+		},
+	}
+
+	testCases := map[string]struct {
+		Format   Format
+		Expected string
+	}{
+		"ndjson": {
+			Format: FormatNDJSON,
+			Expected: `{"path":"a.jpg","outcome":"tagged","utc_time":"2022-08-03T17:56:22Z","match":{"latitude":51.5,"longitude":-0.1,"elevation":75,"method":"nearest","nearest_time":"2022-08-03T17:56:20Z","time_delta":2},"operations":[{"reason":"GPS data not found in EXIF","status":"applied","changes":[{"ifd_path":"IFD/GPSInfo","key":"GPSLatitudeRef","old":null,"new":{"type":"ascii","value":"N"}}]}]}
+{"path":"b.txt","outcome":"unsupported","operations":[]}
+`,
+		},
+		"json": {
+			Format: FormatJSON,
+			Expected: `[
+  {
+    "path": "a.jpg",
+    "outcome": "tagged",
+    "utc_time": "2022-08-03T17:56:22Z",
+    "match": {
+      "latitude": 51.5,
+      "longitude": -0.1,
+      "elevation": 75,
+      "method": "nearest",
+      "nearest_time": "2022-08-03T17:56:20Z",
+      "time_delta": 2
+    },
+    "operations": [
+      {
+        "reason": "GPS data not found in EXIF",
+        "status": "applied",
+        "changes": [
+          {
+            "ifd_path": "IFD/GPSInfo",
+            "key": "GPSLatitudeRef",
+            "old": null,
+            "new": {
+              "type": "ascii",
+              "value": "N"
+            }
+          }
+        ]
+      }
+    ]
+  },
+  {
+    "path": "b.txt",
+    "outcome": "unsupported",
+    "operations": []
+  }
+]
+`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			w := NewRecordWriter(&b, testCase.Format)
+
+			for _, r := range records {
+				require.NoError(t, w.Write(r))
+			}
+			require.NoError(t, w.Close())
+
+			assert.Equal(t, testCase.Expected, b.String())
+		})
+	}
+}
+
+func TestRecordWriterEmptyJSON(t *testing.T) {
+	var b bytes.Buffer
+	w := NewRecordWriter(&b, FormatJSON)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "[]\n", b.String())
+}
@@ -0,0 +1,120 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Outcome is the result of processing a single image
+type Outcome string
+
+const (
+	// OutcomeTagged is used for images which were, or would be, updated
+	OutcomeTagged Outcome = "tagged"
+	// OutcomeAlreadyCorrect is used for images which needed no changes
+	OutcomeAlreadyCorrect Outcome = "already-correct"
+	// OutcomeNoFix is used for images with no GPX point close enough in time
+	OutcomeNoFix Outcome = "no-fix"
+	// OutcomeUnsupported is used for files which aren't supported images or have no EXIF data
+	OutcomeUnsupported Outcome = "unsupported"
+	// OutcomeError is used for images which failed to be processed
+	OutcomeError Outcome = "error"
+)
+
+// Outcomes lists all outcomes in the order they're reported
+var Outcomes = []Outcome{
+	OutcomeTagged,
+	OutcomeAlreadyCorrect,
+	OutcomeNoFix,
+	OutcomeUnsupported,
+	OutcomeError,
+}
+
+// ParseOutcome returns the Outcome with the given name
+func ParseOutcome(name string) (Outcome, error) {
+	for _, o := range Outcomes {
+		if string(o) == name {
+			return o, nil
+		}
+	}
+
+	names := make([]string, len(Outcomes))
+	for i, o := range Outcomes {
+		names[i] = string(o)
+	}
+
+	return "", fmt.Errorf("unknown outcome %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Result is the outcome for a single file
+type Result struct {
+	Path    string
+	Outcome Outcome
+	// Err is the reason for the outcome when the file wasn't tagged
+	Err error
+}
+
+// Report collects the results of processing a set of files
+type Report struct {
+	Results []Result
+}
+
+// Add records the outcome for a file
+func (r *Report) Add(path string, outcome Outcome, err error) {
+	r.Results = append(r.Results, Result{Path: path, Outcome: outcome, Err: err})
+}
+
+// Count returns the number of files with the outcome
+func (r *Report) Count(outcome Outcome) int {
+	count := 0
+	for _, result := range r.Results {
+		if result.Outcome == outcome {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Any returns true if any file has one of the outcomes
+func (r *Report) Any(outcomes []Outcome) bool {
+	for _, o := range outcomes {
+		if r.Count(o) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Summary writes a table of the number of files with each outcome, followed by the
+// files which failed and why
+func (r *Report) Summary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "OUTCOME\tFILES")
+	for _, o := range Outcomes {
+		fmt.Fprintf(tw, "%s\t%d\n", o, r.Count(o))
+	}
+	fmt.Fprintf(tw, "total\t%d\n", len(r.Results))
+
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if r.Count(OutcomeError) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "Errors:")
+	for _, result := range r.Results {
+		if result.Outcome == OutcomeError {
+			fmt.Fprintf(w, "  %s: %s\n", result.Path, result.Err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutcome(t *testing.T) {
+	outcome, err := ParseOutcome("no-fix")
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeNoFix, outcome)
+
+	_, err = ParseOutcome("no fix")
+	assert.Error(t, err)
+}
+
+func TestSummary(t *testing.T) {
+	var r Report
+	r.Add("a.jpg", OutcomeTagged, nil)
+	r.Add("b.jpg", OutcomeTagged, nil)
+	r.Add("c.jpg", OutcomeNoFix, errors.New("too far"))
+	r.Add("d.txt", OutcomeUnsupported, nil)
+	r.Add("e.jpg", OutcomeError, errors.New("corrupt"))
+
+	assert.Equal(t, 2, r.Count(OutcomeTagged))
+	assert.True(t, r.Any([]Outcome{OutcomeAlreadyCorrect, OutcomeError}))
+	assert.False(t, r.Any([]Outcome{OutcomeAlreadyCorrect}))
+	assert.False(t, r.Any(nil))
+
+	var b bytes.Buffer
+	err := r.Summary(&b)
+	require.NoError(t, err)
+
+	expected := `OUTCOME          FILES
+tagged           2
+already-correct  0
+no-fix           1
+unsupported      1
+error            1
+total            5
+Errors:
+  e.jpg: corrupt
+`
+	assert.Equal(t, expected, b.String())
+}
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteFileAtomic replaces the file at path with the data written by write. The data is
+// written to a temporary file in the same directory which is synced and then renamed over
+// path, so path is never left partially written. The permissions of the existing file are kept,
+// and when path is a symlink the file it links to is replaced rather than the link.
+func WriteFileAtomic(path string, write func(w io.Writer) error) (err error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	mode := fs.FileMode(0644)
+	info, err := os.Stat(path)
+	if err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	err = write(f)
+	if err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	err = f.Chmod(mode)
+	if err != nil {
+		return fmt.Errorf("failed to set permissions on temporary file: %w", err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	return ReplaceFile(path, f.Name())
+}
+
+// StageCopy copies the file at path to a new temporary file in the same directory, keeping
+// its permissions and modification time, and returns the copy's path. The copy can be
+// changed and then moved over path with ReplaceFile. When path is a symlink the file it
+// links to is copied.
+func StageCopy(path string) (string, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	err = copyTo(path, f)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+
+	return f.Name(), nil
+}
+
+// ReplaceFile renames staged over path, when path is a symlink the file it links to is
+// replaced rather than the link
+func ReplaceFile(path, staged string) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	err := os.Rename(staged, path)
+	if err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	// sync the directory so that the rename is persisted, not all platforms support this
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	return nil
+}
+
+// Backup copies the file at path before it's modified and returns the path of the copy.
+// When dir is empty the copy is made alongside the file with an .orig suffix, otherwise
+// the copy is made in dir at the file's path relative to the working directory, or at its
+// absolute path when it's outside the working directory. Existing backups are never
+// overwritten: an existing .orig file is kept as it holds an earlier original, and copies
+// in dir get a numbered suffix when the name is taken.
+func Backup(path, dir string) (string, error) {
+	if dir == "" {
+		backupPath := path + ".orig"
+		err := copyFile(path, backupPath)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to backup %s: %w", path, err)
+		}
+		return backupPath, nil
+	}
+
+	rel, err := backupRelPath(path)
+	if err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(dir, rel)
+
+	err = os.MkdirAll(filepath.Dir(backupPath), 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// the copy is made with O_EXCL so that concurrent backups of files with the same
+	// path never take the same name
+	for i := 1; ; i++ {
+		err := copyFile(path, backupPath)
+		if err == nil {
+			return backupPath, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to backup %s: %w", path, err)
+		}
+		backupPath = filepath.Join(dir, fmt.Sprintf("%s.%d", rel, i))
+	}
+}
+
+// backupRelPath returns the path used for the file's backup within a backup directory
+func backupRelPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %s: %w", path, err)
+	}
+
+	wd, err := os.Getwd()
+	if err == nil {
+		rel, err := filepath.Rel(wd, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel, nil
+		}
+	}
+
+	return strings.TrimLeft(abs[len(filepath.VolumeName(abs)):], string(filepath.Separator)), nil
+}
+
+// copyFile copies src to dst keeping the permissions and modification time of src, dst
+// must not exist and is removed if the copy fails
+func copyFile(src, dst string) error {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	return copyTo(src, out)
+}
+
+// copyTo copies src into the new file out, which is closed, and sets the permissions and
+// modification time of out to those of src. out is removed if the copy fails.
+func copyTo(src string, out *os.File) (err error) {
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+
+	err = out.Chmod(info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	err = out.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(out.Name(), info.ModTime(), info.ModTime())
+}
+
+// HashFile returns the hex encoded SHA-256 hash of the file's content
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	testCases := map[string]struct {
+		Write           func(w io.Writer) error
+		ExpectedContent string
+		ExpectError     bool
+	}{
+		"successful write replaces content": {
+			Write: func(w io.Writer) error {
+				_, err := w.Write([]byte("new"))
+				return err
+			},
+			ExpectedContent: "new",
+		},
+		"failed write keeps original": {
+			Write: func(w io.Writer) error {
+				_, err := w.Write([]byte("partial"))
+				if err != nil {
+					return err
+				}
+				return errors.New("encoder failed")
+			},
+			ExpectedContent: "original",
+			ExpectError:     true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "image.jpg")
+
+			err := os.WriteFile(path, []byte("original"), 0600)
+			require.NoError(t, err)
+
+			err = WriteFileAtomic(path, testCase.Write)
+			if testCase.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedContent, string(content))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+			// no temporary files are left behind
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "image.jpg")
+	link := filepath.Join(dir, "link.jpg")
+
+	require.NoError(t, os.WriteFile(target, []byte("original"), 0600))
+	require.NoError(t, os.Symlink(target, link))
+
+	err := WriteFileAtomic(link, func(w io.Writer) error {
+		_, err := w.Write([]byte("new"))
+		return err
+	})
+	require.NoError(t, err)
+
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+}
+
+func TestStageCopy(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "image.jpg")
+	link := filepath.Join(dir, "link.jpg")
+	modTime := time.Date(2022, time.August, 3, 17, 56, 22, 0, time.UTC)
+
+	require.NoError(t, os.WriteFile(target, []byte("original"), 0600))
+	require.NoError(t, os.Chtimes(target, modTime, modTime))
+	require.NoError(t, os.Symlink(target, link))
+
+	staged, err := StageCopy(link)
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(staged))
+
+	info, err := os.Stat(staged)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.Equal(t, modTime, info.ModTime().UTC())
+
+	require.NoError(t, os.WriteFile(staged, []byte("new"), 0600))
+
+	// the original is unchanged until the staged copy replaces it
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+
+	require.NoError(t, ReplaceFile(link, staged))
+
+	info, err = os.Lstat(link)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	content, err = os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+
+	_, err = os.Stat(staged)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestBackup(t *testing.T) {
+	modTime := time.Date(2022, time.August, 3, 17, 56, 22, 0, time.UTC)
+
+	testCases := map[string]struct {
+		// Image is the path of the image relative to the working directory, it's
+		// image.jpg when empty
+		Image            string
+		UseDir           bool
+		ExistingBackups  []string
+		ExpectedBackup   string
+		ExpectedContent  string
+		ExpectModTimeSet bool
+	}{
+		"orig alongside image": {
+			ExpectedBackup:   "image.jpg.orig",
+			ExpectedContent:  "original",
+			ExpectModTimeSet: true,
+		},
+		"existing orig is kept": {
+			ExistingBackups: []string{"image.jpg.orig"},
+			ExpectedBackup:  "image.jpg.orig",
+			ExpectedContent: "earlier",
+		},
+		"backup directory": {
+			UseDir:           true,
+			ExpectedBackup:   "backups/image.jpg",
+			ExpectedContent:  "original",
+			ExpectModTimeSet: true,
+		},
+		"backup directory keeps the relative path": {
+			Image:            "2022/08/image.jpg",
+			UseDir:           true,
+			ExpectedBackup:   "backups/2022/08/image.jpg",
+			ExpectedContent:  "original",
+			ExpectModTimeSet: true,
+		},
+		"backup directory with existing backup": {
+			UseDir:           true,
+			ExistingBackups:  []string{"backups/image.jpg", "backups/image.jpg.1"},
+			ExpectedBackup:   "backups/image.jpg.2",
+			ExpectedContent:  "original",
+			ExpectModTimeSet: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			chdir(t, dir)
+
+			image := testCase.Image
+			if image == "" {
+				image = "image.jpg"
+			}
+
+			err := os.MkdirAll(filepath.Dir(image), 0755)
+			require.NoError(t, err)
+			err = os.WriteFile(image, []byte("original"), 0644)
+			require.NoError(t, err)
+			err = os.Chtimes(image, modTime, modTime)
+			require.NoError(t, err)
+
+			for _, b := range testCase.ExistingBackups {
+				err := os.MkdirAll(filepath.Dir(b), 0755)
+				require.NoError(t, err)
+				err = os.WriteFile(b, []byte("earlier"), 0644)
+				require.NoError(t, err)
+			}
+
+			backupDir := ""
+			if testCase.UseDir {
+				backupDir = "backups"
+			}
+
+			backupPath, err := Backup(image, backupDir)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedBackup, filepath.ToSlash(backupPath))
+
+			content, err := os.ReadFile(backupPath)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedContent, string(content))
+
+			if testCase.ExpectModTimeSet {
+				info, err := os.Stat(backupPath)
+				require.NoError(t, err)
+				assert.Equal(t, modTime, info.ModTime().UTC())
+			}
+		})
+	}
+}
+
+func TestBackupOutsideWorkingDirectory(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	image := filepath.Join(t.TempDir(), "image.jpg")
+	require.NoError(t, os.WriteFile(image, []byte("original"), 0644))
+
+	backupPath, err := Backup(image, "backups")
+	require.NoError(t, err)
+
+	// the image's absolute path is used within the backup directory
+	abs, err := filepath.Abs(image)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("backups", strings.TrimPrefix(abs, string(filepath.Separator))), backupPath)
+
+	content, err := os.ReadFile(backupPath)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+}
+
+// chdir changes the working directory for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}
+
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0644))
+
+	hash, err := HashFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hash)
+}
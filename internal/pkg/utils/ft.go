@@ -15,3 +15,22 @@ func IsJPEGFile(filename string) bool {
 
 	return false
 }
+
+func IsHEIFFile(filename string) bool {
+	lowered := strings.ToLower(filename)
+
+	if strings.HasSuffix(lowered, ".heic") {
+		return true
+	}
+
+	if strings.HasSuffix(lowered, ".heif") {
+		return true
+	}
+
+	return false
+}
+
+// IsImageFile returns true for the image files which can be tagged
+func IsImageFile(filename string) bool {
+	return IsJPEGFile(filename) || IsHEIFFile(filename)
+}
@@ -37,3 +37,63 @@ func TestIsJPEGFile(t *testing.T) {
 		})
 	}
 }
+
+func TestIsHEIFFile(t *testing.T) {
+	testCases := map[string]struct {
+		filename string
+		isHEIF   bool
+	}{
+		"heic": {
+			filename: "foo.heic",
+			isHEIF:   true,
+		},
+		"HEIC": {
+			filename: "foo.HEIC",
+			isHEIF:   true,
+		},
+		"heif": {
+			filename: "foo.heif",
+			isHEIF:   true,
+		},
+		"jpg": {
+			filename: "foo.jpg",
+			isHEIF:   false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsHEIFFile(tc.filename); got != tc.isHEIF {
+				t.Errorf("want %v, got %v", tc.isHEIF, got)
+			}
+		})
+	}
+}
+
+func TestIsImageFile(t *testing.T) {
+	testCases := map[string]struct {
+		filename string
+		isImage  bool
+	}{
+		"jpg": {
+			filename: "foo.jpg",
+			isImage:  true,
+		},
+		"HEIC": {
+			filename: "foo.HEIC",
+			isImage:  true,
+		},
+		"png": {
+			filename: "foo.png",
+			isImage:  false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsImageFile(tc.filename); got != tc.isImage {
+				t.Errorf("want %v, got %v", tc.isImage, got)
+			}
+		})
+	}
+}